@@ -0,0 +1,37 @@
+package windapi
+
+import "time"
+
+// backend is what windObj talks to, it's wind's COM object unless replaced by options, e.g. WithFake
+type backend interface {
+	// start logs in, and returns wind's error code
+	start(option1, option2 string, timeout int32) (int32, error)
+	// stop logs out, and returns wind's error code
+	stop() (int32, error)
+	// connectionState returns 0 if connected
+	connectionState() (int32, error)
+	enableAsyn() error
+
+	// query calls a synchronous method like wss_syn
+	query(method string, args ...interface{}) (*rawData, error)
+	// request issues an asynchronous request like wsq, and returns its request ID and wind's error code,
+	// results of the request are notified by events
+	request(method string, args ...interface{}) (uint64, int32, error)
+	// readdata reads results of a request after an event
+	readdata(reqid uint64) (*rawData, error)
+	cancel(reqid uint64) error
+
+	// events returns the channel of events, it is closed after the backend is closed
+	events() <-chan event
+	close() error
+}
+
+// rawData is the result of a call to the backend
+type rawData struct {
+	codes  []string      // Code列表
+	fields []string      // 指标列表
+	times  []time.Time   // 时间列表
+	data   []interface{} // 数据
+
+	errCode int32 // 错误码
+}
@@ -0,0 +1,243 @@
+package windapi
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Backpressure decides what a subscription does when its consumer falls behind
+type Backpressure int
+
+// backpressure policies
+const (
+	// Block waits for the consumer, it stalls delivery of every subscription, it's the default
+	Block Backpressure = iota
+	// DropOldest drops the oldest message in the buffer to make room for the new one
+	DropOldest
+	// DropNewest drops the new message if the buffer is full
+	DropNewest
+	// CoalesceLatest merges quotes per code while the consumer is busy, keeping the latest value of each field,
+	// and delivers them in a single message once it's ready
+	CoalesceLatest
+	// Spill queues messages without bound
+	Spill
+)
+
+func (p Backpressure) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	case CoalesceLatest:
+		return "coalesce-latest"
+	case Spill:
+		return "spill"
+	}
+	return "unknown"
+}
+
+// pumped returns true if messages are queued and delivered by a pump
+func (p Backpressure) pumped() bool {
+	return p == CoalesceLatest || p == Spill
+}
+
+// SubscribeOption configures a subscription
+type SubscribeOption func(*subscribeOptions)
+
+type subscribeOptions struct {
+	policy Backpressure
+	buffer int
+}
+
+// WithBackpressure sets the policy of a subscription, and the buffer size of its channel, which is at least 1
+func WithBackpressure(policy Backpressure, buffer int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.policy = policy
+		o.buffer = buffer
+	}
+}
+
+func newSubscribeOptions(opts []SubscribeOption) *subscribeOptions {
+	o := &subscribeOptions{
+		policy: Block,
+		buffer: 1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.buffer < 1 {
+		o.buffer = 1
+	}
+	return o
+}
+
+// Dropped returns the number of messages dropped so far,
+// for CoalesceLatest it's the number of field values replaced by later ones
+func (subs *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&subs.dropped)
+}
+
+// queue holds messages not delivered yet for CoalesceLatest and Spill, a pump delivers them
+type queue struct {
+	sync.Mutex
+	wake chan struct{}
+
+	msgs   [][]*WindData
+	latest map[string]*WindData
+	codes  []string
+}
+
+// push hands data over to the consumer according to the policy, it's called by ioloop,
+// only Block waits, until the subscription is closed, or cancel is closed, in which case false is returned
+func (subs *Subscription) push(data []*WindData, cancel <-chan struct{}) bool {
+	subs.sending.Lock()
+	defer subs.sending.Unlock()
+	// it may be closed after ioloop looked it up
+	select {
+	case <-subs.done:
+		return true
+	default:
+	}
+
+	switch subs.policy {
+	case DropOldest:
+		for {
+			select {
+			case subs.c <- data:
+				return true
+			default:
+			}
+			select {
+			case <-subs.c:
+				atomic.AddUint64(&subs.dropped, 1)
+			default:
+			}
+		}
+	case DropNewest:
+		select {
+		case subs.c <- data:
+		default:
+			atomic.AddUint64(&subs.dropped, 1)
+		}
+	case CoalesceLatest, Spill:
+		subs.enqueue(data)
+	default:
+		select {
+		case subs.c <- data:
+		case <-subs.done:
+		case <-cancel:
+			return false
+		}
+	}
+	return true
+}
+
+func (subs *Subscription) enqueue(data []*WindData) {
+	q := &subs.queue
+	q.Lock()
+	if subs.policy == Spill {
+		q.msgs = append(q.msgs, data)
+	} else {
+		for _, d := range data {
+			key := strings.ToUpper(d.WindCode)
+			if prev, ok := q.latest[key]; ok {
+				d = subs.merge(prev, d)
+			} else {
+				q.codes = append(q.codes, key)
+			}
+			q.latest[key] = d
+		}
+	}
+	q.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// merge returns a quote of prev updated by d, pushes may carry only the fields changed,
+// fields of prev replaced by d are counted as dropped
+func (subs *Subscription) merge(prev, d *WindData) *WindData {
+	merged := *d
+	merged.Fields = append([]string(nil), prev.Fields...)
+	merged.Values = append([]interface{}(nil), prev.Values...)
+NEXT:
+	for i, field := range d.Fields {
+		for j, f := range merged.Fields {
+			if strings.EqualFold(f, field) {
+				merged.Values[j] = d.Values[i]
+				atomic.AddUint64(&subs.dropped, 1)
+				continue NEXT
+			}
+		}
+		merged.Fields = append(merged.Fields, field)
+		merged.Values = append(merged.Values, d.Values[i])
+	}
+	return &merged
+}
+
+// dequeue returns the next message in the queue
+func (subs *Subscription) dequeue() ([]*WindData, bool) {
+	q := &subs.queue
+	q.Lock()
+	defer q.Unlock()
+	if subs.policy == Spill {
+		if len(q.msgs) == 0 {
+			return nil, false
+		}
+		data := q.msgs[0]
+		q.msgs[0] = nil
+		q.msgs = q.msgs[1:]
+		return data, true
+	}
+
+	if len(q.codes) == 0 {
+		return nil, false
+	}
+	data := make([]*WindData, len(q.codes))
+	for i, key := range q.codes {
+		data[i] = q.latest[key]
+		delete(q.latest, key)
+	}
+	q.codes = q.codes[:0]
+	return data, true
+}
+
+// pump delivers queued messages to the consumer until the subscription is closed, it owns the channel
+func (subs *Subscription) pump() {
+	defer close(subs.c)
+	for {
+		data, ok := subs.dequeue()
+		if !ok {
+			select {
+			case <-subs.queue.wake:
+				continue
+			case <-subs.done:
+				return
+			}
+		}
+		select {
+		case subs.c <- data:
+		case <-subs.done:
+			return
+		}
+	}
+}
+
+// shutdown closes channels of a closed subscription, it's called with wind.io locked
+func (subs *Subscription) shutdown() {
+	subs.report(SubscriptionEvent{Kind: EventClosed, ReqID: subs.reqid, Err: subs.err})
+	close(subs.events)
+	close(subs.done)
+	// the pump closes the channel, otherwise wait for ioloop to stop pushing, which is woken up by done
+	if !subs.policy.pumped() {
+		subs.sending.Lock()
+		close(subs.c)
+		subs.sending.Unlock()
+	}
+}
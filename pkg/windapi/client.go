@@ -0,0 +1,49 @@
+package windapi
+
+import (
+	"fmt"
+
+	"k8s.io/klog"
+)
+
+// Client is an independent instance of wind's api, with its own COM object and message loop,
+// the package level functions use a default client managed by Open and Close
+type Client struct {
+	*windObj
+}
+
+// New opens a new client of wind's api
+func New(opts ...Option) (*Client, error) {
+	w, err := newAPI(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{windObj: w}, nil
+}
+
+// Close shuts down the client's COM object, and cleans up
+func (c *Client) Close() error {
+	return c.close()
+}
+
+// Logger is used by clients to report what happens inside
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Warningf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// klogLogger logs to klog, it's the default logger
+type klogLogger struct{}
+
+func (klogLogger) Infof(format string, args ...interface{}) {
+	klog.InfoDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (klogLogger) Warningf(format string, args ...interface{}) {
+	klog.WarningDepth(1, fmt.Sprintf(format, args...))
+}
+
+func (klogLogger) Errorf(format string, args ...interface{}) {
+	klog.ErrorDepth(1, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,230 @@
+package windapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SectorAllA is the sector ID of all A shares, e.g. for NewCodeIndex
+const SectorAllA = "a001010100000000"
+
+// ErrCodeNotFound is returned if a name can not be resolved to a wind code
+var ErrCodeNotFound = errors.New("wind: code not found")
+
+// HToCode maps tickers, names or pinyin abbreviations to wind codes,
+// secType is the kind of securities, e.g. "stocks" or "futures".
+// The result is aligned with names, unresolved names get an empty code.
+func HToCode(names, secType, options string, opts ...CallOption) ([]string, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.HToCode(names, secType, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// NewCodeIndex builds a local index from today's constituents of sectors, e.g. SectorAllA,
+// or sector IDs of a futures universe as shown in wind's sector browser.
+// The index matches codes, tickers and names, other names like pinyin abbreviations
+// are resolved by HToCode once, and remembered for later lookups.
+func NewCodeIndex(sectorIDs ...string) (*CodeIndex, error) {
+	return newCodeIndex(WSET, HToCode, sectorIDs)
+}
+
+// HToCode maps tickers, names or pinyin abbreviations to wind codes
+func (wind *windObj) HToCode(names, secType, options string, opts ...CallOption) ([]string, error) {
+	table, err := wind.getTable(opts, "htocode_syn", names, secType, options)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(table.Rows))
+	for _, row := range table.Rows {
+		code, _ := row[0].(string)
+		out = append(out, code)
+	}
+	return out, nil
+}
+
+// NewCodeIndex builds a local index from today's constituents of sectors
+func (wind *windObj) NewCodeIndex(sectorIDs ...string) (*CodeIndex, error) {
+	return newCodeIndex(wind.WSET, wind.HToCode, sectorIDs)
+}
+
+// CodeEntry is a security in CodeIndex
+type CodeEntry struct {
+	WindCode string
+	Ticker   string
+	Name     string
+}
+
+// keys returns what the entry is matched by, in upper case
+func (entry *CodeEntry) keys() []string {
+	return []string{entry.WindCode, entry.Ticker, strings.ToUpper(entry.Name)}
+}
+
+func newCodeEntry(code string) *CodeEntry {
+	entry := &CodeEntry{WindCode: code, Ticker: code}
+	if i := strings.IndexByte(code, '.'); i > 0 {
+		entry.Ticker = code[:i]
+	}
+	return entry
+}
+
+// CodeIndex is a local searchable index of wind codes
+type CodeIndex struct {
+	mu sync.RWMutex
+
+	entries []*CodeEntry
+	codes   map[string]*CodeEntry
+	// aliases are names resolved by htocode
+	aliases map[string]string
+
+	htocode func(names, secType, options string, opts ...CallOption) ([]string, error)
+}
+
+func newCodeIndex(
+	wset func(report, options string, opts ...CallOption) (*Table, error),
+	htocode func(names, secType, options string, opts ...CallOption) ([]string, error),
+	sectorIDs []string,
+) (*CodeIndex, error) {
+	idx := &CodeIndex{
+		codes:   make(map[string]*CodeEntry),
+		aliases: make(map[string]string),
+		htocode: htocode,
+	}
+	date := time.Now().Format(dateLayout)
+	for _, sectorID := range sectorIDs {
+		table, err := wset("sectorconstituent", "date="+date+";sectorid="+sectorID)
+		if err != nil {
+			return nil, fmt.Errorf("wind: failed to load sector %s, %v", sectorID, err)
+		}
+		idx.Add(table)
+	}
+	return idx, nil
+}
+
+// Add adds securities in table to the index, table should have columns wind_code and sec_name
+func (idx *CodeIndex) Add(table *Table) {
+	codeIdx, nameIdx := table.Index("wind_code"), table.Index("sec_name")
+	if codeIdx < 0 {
+		return
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, row := range table.Rows {
+		code, _ := row[codeIdx].(string)
+		if code == "" {
+			continue
+		}
+		if _, ok := idx.codes[code]; ok {
+			continue
+		}
+		entry := newCodeEntry(code)
+		if nameIdx >= 0 {
+			entry.Name, _ = row[nameIdx].(string)
+		}
+		idx.entries = append(idx.entries, entry)
+		idx.codes[code] = entry
+	}
+}
+
+// Lookup returns at most limit entries matching query locally, best matches first,
+// exact matches of code, ticker, name or a remembered alias come before prefix matches, then substring matches
+func (idx *CodeIndex) Lookup(query string, limit int) []*CodeEntry {
+	query = strings.ToUpper(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	type match struct {
+		entry *CodeEntry
+		score int
+	}
+	var matches []match
+	if code, ok := idx.aliases[query]; ok {
+		if entry, ok := idx.codes[code]; ok {
+			matches = append(matches, match{entry, 3})
+		}
+	}
+	for _, entry := range idx.entries {
+		score := 0
+		for _, key := range entry.keys() {
+			switch {
+			case key == query:
+				score = 3
+			case strings.HasPrefix(key, query) && score < 2:
+				score = 2
+			case strings.Contains(key, query) && score < 1:
+				score = 1
+			}
+		}
+		if score > 0 {
+			matches = append(matches, match{entry, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	var out []*CodeEntry
+	seen := make(map[*CodeEntry]bool)
+	for _, m := range matches {
+		if len(out) == limit {
+			break
+		}
+		if !seen[m.entry] {
+			seen[m.entry] = true
+			out = append(out, m.entry)
+		}
+	}
+	return out
+}
+
+// Resolve returns the wind code of name, using the local index if there is an exact match,
+// otherwise name is resolved by htocode, and remembered for later lookups
+func (idx *CodeIndex) Resolve(name, secType string) (string, error) {
+	key := strings.ToUpper(strings.TrimSpace(name))
+	idx.mu.RLock()
+	code, ok := idx.aliases[key]
+	for _, entry := range idx.entries {
+		if ok {
+			break
+		}
+		for _, k := range entry.keys() {
+			if k == key {
+				code, ok = entry.WindCode, true
+				break
+			}
+		}
+	}
+	idx.mu.RUnlock()
+	if ok {
+		return code, nil
+	}
+
+	codes, err := idx.htocode(name, secType, "")
+	if err != nil {
+		return "", err
+	}
+	if len(codes) == 0 || codes[0] == "" {
+		return "", ErrCodeNotFound
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.aliases[key] = codes[0]
+	if _, ok := idx.codes[codes[0]]; !ok {
+		entry := newCodeEntry(codes[0])
+		idx.entries = append(idx.entries, entry)
+		idx.codes[codes[0]] = entry
+	}
+	return codes[0], nil
+}
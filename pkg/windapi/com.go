@@ -0,0 +1,413 @@
+package windapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	ole "restis.dev/go-ole"
+	"restis.dev/go-wind/pkg/errs"
+)
+
+// thread messages handled by the message loop
+const (
+	wmQuit = 0x0012
+	// wmCall wakes up the message loop to run queued calls, it's WM_USER+1
+	wmCall = 0x0400 + 1
+)
+
+// repostInterval is how often wmCall is posted again while a call is waiting to run
+const repostInterval = 100 * time.Millisecond
+
+var errBackendClosed = errors.New("wind: COM backend is closed")
+
+// comBackend calls wind's COM object, the object lives in an OS thread running a message loop,
+// and every call to it is queued and run on that thread, as required by the apartment it lives in
+type comBackend struct {
+	wind *ole.IDispatch
+
+	msgloop struct {
+		running bool // flag if main loop is running
+		calls   chan func()
+		exited  chan struct{}
+	}
+
+	evtsink *eventReceiver
+
+	C chan event
+
+	ctx struct {
+		context.Context
+		cancel context.CancelFunc
+		tid    uint32
+	}
+
+	wg sync.WaitGroup
+
+	log Logger
+}
+
+// newCOMBackend creates wind's COM object, and starts a message loop for it
+func newCOMBackend(log Logger) (*comBackend, error) {
+	b := &comBackend{log: log}
+	b.ctx.Context, b.ctx.cancel = context.WithCancel(context.Background())
+	b.msgloop.calls = make(chan func(), 64)
+	b.msgloop.exited = make(chan struct{})
+	b.C = make(chan event, 1)
+
+	// All initialisation should occur in the same OS thread,
+	// for it's main message loop to reside in.
+	// Here we use a error channel to coordinate this process
+	waitErrC := make(chan error)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer close(b.msgloop.exited)
+
+		// lock on current os thread
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		eventC := make(chan event, 1)
+		defer close(eventC)
+
+		// init message loop
+		if err := func() error {
+			// COINIT_APARTMENTTHREADED, the object is called on this thread only
+			if err := ole.CoInitializeEx(0, 0x2); err != nil {
+				return err
+			}
+
+			unknown, err := createObject(ProgramID)
+			if err != nil {
+				return err
+			}
+			defer unknown.Release()
+
+			b.wind, err = unknown.QueryInterface(ole.IID_IDispatch)
+			if err != nil {
+				return err
+			}
+
+			// connect event source to sink
+			r := newEventReceiver(eventC)
+			if err := adviseEventReceiver(b.wind, r); err != nil {
+				return errs.And(err, b.release())
+			}
+			b.evtsink = r
+
+			return nil
+		}(); err != nil {
+			waitErrC <- err
+			return
+		}
+
+		// the sink runs on this thread, it must not block on consumers calling back into the loop
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			pumpEvents(eventC, b.C)
+		}()
+
+		b.ctx.tid = getCurrentThreadID()
+		b.msgloop.running = true
+
+		// init finished,
+		// notify outside world, we r ready to work
+		close(waitErrC)
+
+		b.log.Infof("(wind) message loop started at tid: %d", b.ctx.tid)
+
+		defer b.log.Infof("(wind) message loop exited")
+		defer ole.CoUninitialize()
+		defer func() { b.msgloop.running = false }()
+
+		var m ole.Msg
+		for b.ctx.Err() == nil {
+			rc, _ := ole.GetMessage(&m, 0, 0, 0)
+			if rc == 0 {
+				b.log.Infof("(wind) message loop with return code: 0")
+				break
+			}
+			if rc == -1 {
+				continue
+			}
+			if m.Message == wmCall {
+				b.runCalls()
+				continue
+			}
+			ole.DispatchMessage(&m)
+		}
+	}()
+
+	if err := <-waitErrC; err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// do queues fn, wakes up the message loop to run it, and waits for it to return
+func (b *comBackend) do(fn func()) error {
+	var (
+		done     = make(chan struct{})
+		released bool
+	)
+	select {
+	case b.msgloop.calls <- func() {
+		defer close(done)
+		if released = b.wind == nil; !released {
+			fn()
+		}
+	}:
+	case <-b.ctx.Done():
+		return errBackendClosed
+	}
+
+	// the message may fail to be posted, or be swallowed by a nested modal loop,
+	// keep posting until the call runs, extra messages find nothing to run
+	b.wake()
+	ticker := time.NewTicker(repostInterval)
+	defer ticker.Stop()
+	for waiting := true; waiting; {
+		select {
+		case <-done:
+			waiting = false
+		case <-ticker.C:
+			b.wake()
+		case <-b.msgloop.exited:
+			select {
+			case <-done:
+				waiting = false
+			default:
+				return errBackendClosed
+			}
+		}
+	}
+	if released {
+		return errBackendClosed
+	}
+	return nil
+}
+
+// wake posts wmCall to the message loop
+func (b *comBackend) wake() {
+	if r0, err := postThreadMessage(b.ctx.tid, wmCall); r0 == 0 {
+		b.log.Warningf("(wind) post call message with error: %v(%v)", err, r0)
+	}
+}
+
+// runCalls runs queued calls on the loop thread
+func (b *comBackend) runCalls() {
+	for {
+		select {
+		case fn := <-b.msgloop.calls:
+			fn()
+		default:
+			return
+		}
+	}
+}
+
+// call invokes method of wind's COM object on the loop thread
+func (b *comBackend) call(method string, args ...interface{}) (res *ole.VARIANT, err error) {
+	if derr := b.do(func() {
+		res, err = callMethod(b.wind, method, args...)
+	}); derr != nil {
+		return nil, derr
+	}
+	return
+}
+
+func (b *comBackend) start(option1, option2 string, timeout int32) (int32, error) {
+	res, err := b.call("start_cpp", option1, option2, timeout)
+	if err != nil {
+		return 0, err
+	}
+	return int32(res.Val), nil
+}
+
+func (b *comBackend) stop() (int32, error) {
+	res, err := b.call("stop")
+	if err != nil {
+		return 0, err
+	}
+	return int32(res.Val), nil
+}
+
+func (b *comBackend) connectionState() (state int32, err error) {
+	if derr := b.do(func() {
+		if !b.msgloop.running {
+			err = errors.New("wind: message loop is not running")
+			return
+		}
+		_, err = callMethod(b.wind, "getConnectionState", &state)
+	}); derr != nil {
+		return 0, derr
+	}
+	return
+}
+
+func (b *comBackend) enableAsyn() (err error) {
+	_, err = b.call("enableAsyn", 1)
+	return
+}
+
+func (b *comBackend) query(method string, args ...interface{}) (raw *rawData, err error) {
+	if derr := b.do(func() {
+		var (
+			codes, fields, times ole.VARIANT
+			ec                   int32
+		)
+		res, cerr := callMethod(b.wind, method, append(args, &codes, &fields, &times, &ec)...)
+		if cerr != nil {
+			err = cerr
+			return
+		}
+		raw, err = toRawData(res, &codes, &fields, &times, ec)
+	}); derr != nil {
+		return nil, derr
+	}
+	return
+}
+
+func (b *comBackend) request(method string, args ...interface{}) (uint64, int32, error) {
+	var errCode int32
+	res, err := b.call(method, append(args, &errCode)...)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint64(res.Val), errCode, nil
+}
+
+func (b *comBackend) readdata(reqid uint64) (raw *rawData, err error) {
+	if derr := b.do(func() {
+		var (
+			codes, fields, times ole.VARIANT
+			rs, ec               int32
+		)
+		res, cerr := callMethod(b.wind, "readdata", reqid, &codes, &fields, &times, &rs, &ec)
+		if cerr != nil {
+			err = cerr
+			return
+		}
+		raw, err = toRawData(res, &codes, &fields, &times, ec)
+	}); derr != nil {
+		return nil, derr
+	}
+	return
+}
+
+func (b *comBackend) cancel(reqid uint64) error {
+	_, err := b.call("cancelRequest", reqid)
+	return err
+}
+
+func (b *comBackend) events() <-chan event {
+	return b.C
+}
+
+func (b *comBackend) close() (err error) {
+	if b.ctx.Err() != nil {
+		return nil
+	}
+
+	if derr := b.do(func() {
+		err = b.release()
+	}); derr != nil {
+		err = derr
+	}
+	b.ctx.cancel()
+
+	if b.ctx.tid != 0 {
+		if r0, perr := postThreadMessage(b.ctx.tid, wmQuit); r0 != 0 {
+			b.wg.Wait()
+		} else {
+			b.log.Warningf("(wind) post close message with error: %v(%v)", perr, r0)
+		}
+		b.ctx.tid = 0
+	}
+	return
+}
+
+// release disconnects the event sink and releases the COM object, it runs on the loop thread
+func (b *comBackend) release() (err error) {
+	if b.evtsink != nil {
+		err = unadviseEventReceiver(b.wind, b.evtsink)
+		b.evtsink = nil
+	}
+	if b.wind != nil {
+		b.wind.Release()
+		b.wind = nil
+	}
+	return
+}
+
+// pumpEvents forwards events from in to out without blocking the sender,
+// events not delivered yet are dropped once in is closed
+func pumpEvents(in <-chan event, out chan<- event) {
+	defer close(out)
+
+	var queue []event
+	for {
+		var (
+			send chan<- event
+			next event
+		)
+		if len(queue) > 0 {
+			send, next = out, queue[0]
+		}
+
+		select {
+		case evt, ok := <-in:
+			if !ok {
+				return
+			}
+			queue = append(queue, evt)
+		case send <- next:
+			queue = queue[1:]
+		}
+	}
+}
+
+// toRawData converts results of a COM call, and clears them
+func toRawData(data, codes, fields, times *ole.VARIANT, ec int32) (raw *rawData, err error) {
+	defer func() {
+		err = errs.And(err, codes.Clear(), fields.Clear(), times.Clear(), data.Clear())
+	}()
+
+	raw = &rawData{errCode: ec}
+	if ec != 0 {
+		return raw, nil
+	}
+	if arr := codes.ToArray(); arr != nil {
+		raw.codes = arr.ToStringArray()
+	}
+	if arr := fields.ToArray(); arr != nil {
+		raw.fields = arr.ToStringArray()
+	}
+	if arr := times.ToArray(); arr != nil {
+		for _, tval := range arr.ToValueArray() {
+			ts, ok := tval.(float64)
+			if !ok {
+				return nil, fmt.Errorf("wind: invalid time %v", tval)
+			}
+			raw.times = append(raw.times, msTsToTime(ts))
+		}
+	}
+	if arr := data.ToArray(); arr != nil {
+		raw.data = arr.ToValueArray()
+	}
+	return raw, nil
+}
+
+func msTsToTime(msts float64) time.Time {
+	val := msts - 693960
+	day := time.Duration(int64(val))
+	ns := time.Millisecond * time.Duration((val*float64(time.Hour*24)-float64(day*time.Hour*24))/float64(time.Millisecond)+0.5)
+	return time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local).Add(day*time.Hour*24 + ns)
+}
@@ -2,6 +2,7 @@ package windapi
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -37,3 +38,99 @@ func (data *WindData) String() string {
 	}
 	return string(bts)
 }
+
+// Series is a time-indexed sequence of one field of one code
+type Series struct {
+	WindCode string
+	Field    string
+
+	Times  []time.Time
+	Values []interface{}
+}
+
+func (s *Series) String() string {
+	bts, err := json.Marshal(&struct {
+		WindCode string        `json:"WindCode"`
+		Field    string        `json:"Field"`
+		Times    []time.Time   `json:"Times"`
+		Values   []interface{} `json:"Values"`
+	}{
+		WindCode: s.WindCode,
+		Field:    s.Field,
+		Times:    s.Times,
+		Values:   s.Values,
+	})
+	if err != nil {
+		return "wind: err, " + err.Error()
+	}
+	return string(bts)
+}
+
+// toSeries regroups entries into one series per code and field,
+// in the order they first appear
+func toSeries(data []*WindData) []*Series {
+	var (
+		out   []*Series
+		index = make(map[[2]string]*Series)
+	)
+	for _, d := range data {
+		for i, field := range d.Fields {
+			key := [2]string{d.WindCode, field}
+			s, ok := index[key]
+			if !ok {
+				s = &Series{WindCode: d.WindCode, Field: field}
+				index[key] = s
+				out = append(out, s)
+			}
+			s.Times = append(s.Times, d.UpdateTime)
+			s.Values = append(s.Values, d.Values[i])
+		}
+	}
+	return out
+}
+
+// Table is a set of rows with named columns, returned from reports like WSET
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Index returns the position of the named column, or -1 if absent,
+// column names are case insensitive as in wind
+func (t *Table) Index(name string) int {
+	for i, col := range t.Columns {
+		if strings.EqualFold(col, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Column returns values of the named column, or nil if absent
+func (t *Table) Column(name string) []interface{} {
+	idx := t.Index(name)
+	if idx < 0 {
+		return nil
+	}
+	out := make([]interface{}, len(t.Rows))
+	for i, row := range t.Rows {
+		out[i] = row[idx]
+	}
+	return out
+}
+
+func (t *Table) String() string {
+	rows := make([]map[string]interface{}, len(t.Rows))
+	for i, row := range t.Rows {
+		mp := make(map[string]interface{})
+		for j := range t.Columns {
+			mp[t.Columns[j]] = row[j]
+		}
+		rows[i] = mp
+	}
+	bts, err := json.Marshal(rows)
+	if err != nil {
+		return "wind: err, " + err.Error()
+	}
+	return string(bts)
+}
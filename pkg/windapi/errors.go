@@ -1,56 +1,157 @@
 package windapi
 
-import (
-	"errors"
-	"fmt"
+import "fmt"
+
+// Category is the kind of an error reported by wind
+type Category int
+
+// categories of wind's errors
+const (
+	CategoryUnknown Category = iota
+	CategoryLogin
+	CategoryNetwork
+	CategoryRequest
+	CategoryPermission
+	CategoryQuota
 )
 
-var errMap = map[int32]error{
-	-40520001: errors.New("wind: 未知错误(-40520001)"),
-	-40520002: errors.New("wind: 内部错误(-40520002)"),
-	-40520003: errors.New("wind: 系统错误(-40520003)"),
-	-40520004: errors.New("wind: 登录失败(-40520004)"),
-	-40520005: errors.New("wind: 无权限(-40520005)"),
-	-40520006: errors.New("wind: 用户取消(-40520006)"),
-	-40520007: errors.New("wind: 无数据(-40520007)"),
-	-40520008: errors.New("wind: 超时错误(-40520008)"),
-	-40520009: errors.New("wind: 本地WBOX错误(-40520009)"),
-	-40520010: errors.New("wind: 需要内容不存在(-40520010)"),
-	-40520011: errors.New("wind: 需要服务器不存在(-40520011)"),
-	-40520012: errors.New("wind: 引用不存在(-40520012)"),
-	-40520013: errors.New("wind: 其他地方登录错误(-40520013)"),
-	-40520014: errors.New("wind: 未登录使用WIM工具，故无法登录(-40520014)"),
-	-40520015: errors.New("wind: 连续登录失败次数过多(-40520015)"),
-	-40521001: errors.New("wind: IO操作错误(-40521001)"),
-	-40521002: errors.New("wind: 后台服务器不可用(-40521002)"),
-	-40521003: errors.New("wind: 网络连接失败(-40521003)"),
-	-40521004: errors.New("wind: 请求发送失败(-40521004)"),
-	-40521005: errors.New("wind: 数据接收失败(-40521005)"),
-	-40521006: errors.New("wind: 网络错误(-40521006)"),
-	-40521007: errors.New("wind: 服务器拒绝请求(-40521007)"),
-	-40521008: errors.New("wind: 错误的应答(-40521008)"),
-	-40521009: errors.New("wind: 数据解码失败(-40521009)"),
-	-40521010: errors.New("wind: 网络超时(-40521010)"),
-	-40521011: errors.New("wind: 频繁访问(-40521011)"),
-	-40522001: errors.New("wind: 无合法会话(-40522001)"),
-	-40522002: errors.New("wind: 非法数据服务(-40522002)"),
-	-40522003: errors.New("wind: 非法请求(-40522003)"),
-	-40522004: errors.New("wind: 万得代码语法错误(-40522004)"),
-	-40522005: errors.New("wind: 不支持的万得代码(-40522005)"),
-	-40522006: errors.New("wind: 指标语法错误(-40522006)"),
-	-40522007: errors.New("wind: 不支持的指标(-40522007)"),
-	-40522008: errors.New("wind: 指标参数语法错误(-40522008)"),
-	-40522009: errors.New("wind: 不支持的指标参数(-40522009)"),
-	-40522010: errors.New("wind: 日期与时间语法错误(-40522010)"),
-	-40522011: errors.New("wind: 不支持的日期与时间(-40522011)"),
-	-40522012: errors.New("wind: 不支持的请求参数(-40522012)"),
-	-40522013: errors.New("wind: 数组下标越界(-40522013)"),
-	-40522014: errors.New("wind: 重复的WQID(-40522014)"),
-	-40522015: errors.New("wind: 请求无相应权限(-40522015)"),
-	-40522016: errors.New("wind: 不支持的数据类型(-40522016)"),
-	-40522017: errors.New("wind: 数据提取量超限(-40522017)"),
+func (c Category) String() string {
+	switch c {
+	case CategoryLogin:
+		return "login"
+	case CategoryNetwork:
+		return "network"
+	case CategoryRequest:
+		return "request"
+	case CategoryPermission:
+		return "permission"
+	case CategoryQuota:
+		return "quota"
+	}
+	return "unknown"
+}
+
+// WindError is an error code reported by wind
+type WindError struct {
+	Code     int32
+	Category Category
+	Msg      string
+}
+
+func (e *WindError) Error() string {
+	return fmt.Sprintf("wind: %s(%d)", e.Msg, e.Code)
+}
+
+// Is reports whether target is a WindError of the same code,
+// so that errors.Is works with the sentinels below
+func (e *WindError) Is(target error) bool {
+	t, ok := target.(*WindError)
+	return ok && t.Code == e.Code
 }
 
+// Temporary reports whether the error is caused by a transient condition,
+// i.e. network failures, timeouts and too frequent access
+func (e *WindError) Temporary() bool {
+	return e.Code == -40520008 || (e.Code <= -40521001 && e.Code >= -40521999)
+}
+
+// Retryable reports whether the same request may succeed if sent again,
+// syntax, permission and login errors are never retryable
+func (e *WindError) Retryable() bool {
+	return e.Temporary()
+}
+
+func newWindError(code int32, msg string) *WindError {
+	return &WindError{Code: code, Category: categoryOf(code), Msg: msg}
+}
+
+func categoryOf(code int32) Category {
+	switch code {
+	case -40520004, -40520013, -40520014, -40520015, -40522001:
+		return CategoryLogin
+	case -40520005, -40522015:
+		return CategoryPermission
+	case -40520008:
+		return CategoryNetwork
+	case -40521011, -40522017:
+		return CategoryQuota
+	case -40520006, -40520007, -40520010, -40520011, -40520012:
+		return CategoryRequest
+	}
+	switch {
+	case code <= -40521001 && code >= -40521999:
+		return CategoryNetwork
+	case code <= -40522001 && code >= -40522999:
+		return CategoryRequest
+	}
+	return CategoryUnknown
+}
+
+// well known errors reported by wind, use errors.Is to check against them
+var (
+	ErrUnknown              = newWindError(-40520001, "未知错误")
+	ErrInternal             = newWindError(-40520002, "内部错误")
+	ErrSystem               = newWindError(-40520003, "系统错误")
+	ErrLoginFailed          = newWindError(-40520004, "登录失败")
+	ErrNoPermission         = newWindError(-40520005, "无权限")
+	ErrUserCanceled         = newWindError(-40520006, "用户取消")
+	ErrNoData               = newWindError(-40520007, "无数据")
+	ErrTimeout              = newWindError(-40520008, "超时错误")
+	ErrLocalWBox            = newWindError(-40520009, "本地WBOX错误")
+	ErrContentNotFound      = newWindError(-40520010, "需要内容不存在")
+	ErrServerNotFound       = newWindError(-40520011, "需要服务器不存在")
+	ErrReferenceNotFound    = newWindError(-40520012, "引用不存在")
+	ErrLoggedInElsewhere    = newWindError(-40520013, "其他地方登录错误")
+	ErrWIMNotLoggedIn       = newWindError(-40520014, "未登录使用WIM工具，故无法登录")
+	ErrTooManyLoginFailures = newWindError(-40520015, "连续登录失败次数过多")
+	ErrIO                   = newWindError(-40521001, "IO操作错误")
+	ErrServerUnavailable    = newWindError(-40521002, "后台服务器不可用")
+	ErrConnectionFailed     = newWindError(-40521003, "网络连接失败")
+	ErrSendFailed           = newWindError(-40521004, "请求发送失败")
+	ErrReceiveFailed        = newWindError(-40521005, "数据接收失败")
+	ErrNetwork              = newWindError(-40521006, "网络错误")
+	ErrRequestRejected      = newWindError(-40521007, "服务器拒绝请求")
+	ErrBadResponse          = newWindError(-40521008, "错误的应答")
+	ErrDecodeFailed         = newWindError(-40521009, "数据解码失败")
+	ErrNetworkTimeout       = newWindError(-40521010, "网络超时")
+	ErrTooFrequent          = newWindError(-40521011, "频繁访问")
+	ErrNoSession            = newWindError(-40522001, "无合法会话")
+	ErrInvalidDataService   = newWindError(-40522002, "非法数据服务")
+	ErrInvalidRequest       = newWindError(-40522003, "非法请求")
+	ErrCodeSyntax           = newWindError(-40522004, "万得代码语法错误")
+	ErrUnsupportedCode      = newWindError(-40522005, "不支持的万得代码")
+	ErrFieldSyntax          = newWindError(-40522006, "指标语法错误")
+	ErrUnsupportedField     = newWindError(-40522007, "不支持的指标")
+	ErrParamSyntax          = newWindError(-40522008, "指标参数语法错误")
+	ErrUnsupportedParam     = newWindError(-40522009, "不支持的指标参数")
+	ErrDateSyntax           = newWindError(-40522010, "日期与时间语法错误")
+	ErrUnsupportedDate      = newWindError(-40522011, "不支持的日期与时间")
+	ErrUnsupportedOption    = newWindError(-40522012, "不支持的请求参数")
+	ErrIndexOutOfRange      = newWindError(-40522013, "数组下标越界")
+	ErrDuplicateWQID        = newWindError(-40522014, "重复的WQID")
+	ErrRequestNoPermission  = newWindError(-40522015, "请求无相应权限")
+	ErrUnsupportedDataType  = newWindError(-40522016, "不支持的数据类型")
+	ErrDataLimit            = newWindError(-40522017, "数据提取量超限")
+)
+
+var errMap = func() map[int32]*WindError {
+	mp := make(map[int32]*WindError)
+	for _, err := range []*WindError{
+		ErrUnknown, ErrInternal, ErrSystem, ErrLoginFailed, ErrNoPermission,
+		ErrUserCanceled, ErrNoData, ErrTimeout, ErrLocalWBox, ErrContentNotFound,
+		ErrServerNotFound, ErrReferenceNotFound, ErrLoggedInElsewhere, ErrWIMNotLoggedIn, ErrTooManyLoginFailures,
+		ErrIO, ErrServerUnavailable, ErrConnectionFailed, ErrSendFailed, ErrReceiveFailed,
+		ErrNetwork, ErrRequestRejected, ErrBadResponse, ErrDecodeFailed, ErrNetworkTimeout,
+		ErrTooFrequent, ErrNoSession, ErrInvalidDataService, ErrInvalidRequest, ErrCodeSyntax,
+		ErrUnsupportedCode, ErrFieldSyntax, ErrUnsupportedField, ErrParamSyntax, ErrUnsupportedParam,
+		ErrDateSyntax, ErrUnsupportedDate, ErrUnsupportedOption, ErrIndexOutOfRange, ErrDuplicateWQID,
+		ErrRequestNoPermission, ErrUnsupportedDataType, ErrDataLimit,
+	} {
+		mp[err.Code] = err
+	}
+	return mp
+}()
+
 func parseErr(errCode int32) error {
 	if 0 == errCode {
 		return nil
@@ -58,5 +159,5 @@ func parseErr(errCode int32) error {
 	if err, ok := errMap[errCode]; ok {
 		return err
 	}
-	return fmt.Errorf("wind: unknown error(%d)", errCode)
+	return newWindError(errCode, "unknown error")
 }
@@ -0,0 +1,239 @@
+package windapi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeResult is the result of a call to Fake
+type FakeResult struct {
+	Codes  []string
+	Fields []string
+	Times  []time.Time
+	Data   []interface{}
+
+	// ErrCode is reported as wind's error code if not 0
+	ErrCode int32
+}
+
+// FakeRequest is an asynchronous request issued to Fake, e.g. wsq
+type FakeRequest struct {
+	ID       uint64
+	Method   string
+	Args     []interface{}
+	Canceled bool
+}
+
+// FakeHandler handles calls of a method to Fake
+type FakeHandler func(args []interface{}) *FakeResult
+
+// Fake is a scriptable in-memory backend, it lets clients run without a wind terminal, e.g. in tests.
+// Synchronous calls are answered by handlers, and results of requests are pushed along with events.
+type Fake struct {
+	mu sync.Mutex
+
+	handlers map[string]FakeHandler
+	requests []*FakeRequest
+	pending  map[uint64][]*FakeResult
+
+	nextID uint64
+	state  int32
+	asyn   bool
+
+	emit struct {
+		sync.Mutex
+		c      chan event
+		done   chan struct{}
+		closed bool
+	}
+}
+
+// NewFake creates a fake backend, pass it to New with WithFake
+func NewFake() *Fake {
+	f := &Fake{
+		handlers: make(map[string]FakeHandler),
+		pending:  make(map[uint64][]*FakeResult),
+	}
+	f.emit.c = make(chan event, 64)
+	f.emit.done = make(chan struct{})
+	return f
+}
+
+// WithFake makes the api talk to a fake backend instead of wind's COM object
+func WithFake(f *Fake) Option {
+	return func(o *options) {
+		o.newBackend = func(*options) (backend, error) {
+			return f, nil
+		}
+	}
+}
+
+// Handle sets the handler of method, e.g. "wss_syn",
+// the result of start_cpp and stop is taken from FakeResult.ErrCode
+func (f *Fake) Handle(method string, fn FakeHandler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[method] = fn
+}
+
+// Push queues result for the next readdata of reqid, and notifies it with an event
+func (f *Fake) Push(reqid uint64, result *FakeResult) {
+	f.mu.Lock()
+	f.pending[reqid] = append(f.pending[reqid], result)
+	f.mu.Unlock()
+	f.Emit(1, reqid, 0)
+}
+
+// Emit sends an event as if it's from wind's event sink
+func (f *Fake) Emit(state int32, reqid uint64, errCode int32) {
+	f.emit.Lock()
+	defer f.emit.Unlock()
+	if f.emit.closed {
+		return
+	}
+	select {
+	case f.emit.c <- event{State: state, RequestID: int64(reqid), ErrCode: errCode}:
+	case <-f.emit.done:
+	}
+}
+
+// SetConnectionState sets the state reported by getConnectionState, 0 means connected
+func (f *Fake) SetConnectionState(state int32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.state = state
+}
+
+// Requests returns requests issued so far
+func (f *Fake) Requests() []FakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]FakeRequest, len(f.requests))
+	for i, req := range f.requests {
+		out[i] = *req
+	}
+	return out
+}
+
+func (f *Fake) call(method string, args []interface{}) (*FakeResult, bool) {
+	f.mu.Lock()
+	fn, ok := f.handlers[method]
+	f.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	res := fn(args)
+	if res == nil {
+		res = &FakeResult{}
+	}
+	return res, true
+}
+
+func (f *Fake) start(option1, option2 string, timeout int32) (int32, error) {
+	if res, ok := f.call("start_cpp", []interface{}{option1, option2, timeout}); ok {
+		return res.ErrCode, nil
+	}
+	return 0, nil
+}
+
+func (f *Fake) stop() (int32, error) {
+	if res, ok := f.call("stop", nil); ok {
+		return res.ErrCode, nil
+	}
+	return 0, nil
+}
+
+func (f *Fake) connectionState() (int32, error) {
+	select {
+	case <-f.emit.done:
+		return 0, errors.New("wind: fake is closed")
+	default:
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.state, nil
+}
+
+func (f *Fake) enableAsyn() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.asyn = true
+	return nil
+}
+
+func (f *Fake) query(method string, args ...interface{}) (*rawData, error) {
+	res, ok := f.call(method, args)
+	if !ok {
+		return nil, fmt.Errorf("wind: fake has no handler for %s", method)
+	}
+	return res.raw(), nil
+}
+
+func (f *Fake) request(method string, args ...interface{}) (uint64, int32, error) {
+	if res, ok := f.call(method, args); ok && res.ErrCode != 0 {
+		return 0, res.ErrCode, nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	f.requests = append(f.requests, &FakeRequest{
+		ID:     f.nextID,
+		Method: method,
+		Args:   args,
+	})
+	return f.nextID, 0, nil
+}
+
+func (f *Fake) readdata(reqid uint64) (*rawData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	results := f.pending[reqid]
+	if len(results) == 0 {
+		return nil, fmt.Errorf("wind: fake has no data for %d", reqid)
+	}
+	f.pending[reqid] = results[1:]
+	return results[0].raw(), nil
+}
+
+func (f *Fake) cancel(reqid uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, req := range f.requests {
+		if reqid == 0 || req.ID == reqid {
+			req.Canceled = true
+		}
+	}
+	return nil
+}
+
+func (f *Fake) events() <-chan event {
+	return f.emit.c
+}
+
+func (f *Fake) close() error {
+	select {
+	case <-f.emit.done:
+		return nil
+	default:
+	}
+	// unblock pending emits before closing the channel
+	close(f.emit.done)
+	f.emit.Lock()
+	defer f.emit.Unlock()
+	f.emit.closed = true
+	close(f.emit.c)
+	return nil
+}
+
+func (res *FakeResult) raw() *rawData {
+	return &rawData{
+		codes:   res.Codes,
+		fields:  res.Fields,
+		times:   res.Times,
+		data:    res.Data,
+		errCode: res.ErrCode,
+	}
+}
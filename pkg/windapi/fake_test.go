@@ -0,0 +1,870 @@
+package windapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newFakeClient(t *testing.T) (*Client, *Fake) {
+	fake := NewFake()
+	c, err := New(WithFake(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, fake
+}
+
+func TestFakeWSS(t *testing.T) {
+	c, fake := newFakeClient(t)
+	defer func() { panicOnErr(c.Close()) }()
+
+	now := time.Now()
+	fake.Handle("wss_syn", func(args []interface{}) *FakeResult {
+		if args[0] != "600588.SH,000001.SZ" || args[1] != "sec_name" {
+			t.Errorf("unexpected args: %v", args)
+		}
+		return &FakeResult{
+			Codes:  []string{"600588.SH", "000001.SZ"},
+			Fields: []string{"sec_name"},
+			Times:  []time.Time{now},
+			Data:   []interface{}{"用友网络", "平安银行"},
+		}
+	})
+
+	data, err := c.WSS("600588.SH,000001.SZ", "sec_name", "")
+	panicOnErr(err)
+	if len(data) != 2 || data[1].WindCode != "000001.SZ" || data[1].Values[0] != "平安银行" || !data[1].UpdateTime.Equal(now) {
+		t.Errorf("unexpected data: %v", data)
+	}
+}
+
+func TestFakeErrors(t *testing.T) {
+	c, fake := newFakeClient(t)
+	defer func() { panicOnErr(c.Close()) }()
+
+	fake.Handle("wss_syn", func(args []interface{}) *FakeResult {
+		return &FakeResult{ErrCode: -40522007}
+	})
+	if _, err := c.WSS("600588.SH", "bad_field", ""); !errors.Is(err, ErrUnsupportedField) {
+		t.Errorf("expected ErrUnsupportedField, got %v", err)
+	}
+
+	var calls int
+	fake.Handle("wss_syn", func(args []interface{}) *FakeResult {
+		if calls++; calls == 1 {
+			return &FakeResult{ErrCode: -40521010}
+		}
+		return &FakeResult{}
+	})
+	_, err := c.WSS("600588.SH", "sec_name", "", WithRetry(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+	if err != nil || calls != 2 {
+		t.Errorf("expected success on retry, got %d calls with %v", calls, err)
+	}
+
+	// uploads are not retried by default
+	calls = 0
+	fake.Handle("wupf_syn", func(args []interface{}) *FakeResult {
+		calls++
+		return &FakeResult{ErrCode: -40521010}
+	})
+	holdings := []PortfolioHolding{{TradeDate: time.Now(), WindCode: "600588.SH", Quantity: 100, CostPrice: 10.5}}
+	if err := c.WUPF("test", holdings, ""); err == nil || calls != 1 {
+		t.Errorf("expected a single failed upload, got %d calls with %v", calls, err)
+	}
+}
+
+func TestFakeLoginFailed(t *testing.T) {
+	fake := NewFake()
+	fake.Handle("start_cpp", func(args []interface{}) *FakeResult {
+		return &FakeResult{ErrCode: -40520004}
+	})
+	if _, err := New(WithFake(fake)); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("expected ErrLoginFailed, got %v", err)
+	}
+
+	// switching to a bad account ends the former session anyway
+	fake = NewFake()
+	fake.Handle("start_cpp", func(args []interface{}) *FakeResult {
+		if strings.Contains(args[0].(string), "mallory") {
+			return &FakeResult{ErrCode: -40520004}
+		}
+		return nil
+	})
+	c, err := New(WithFake(fake))
+	panicOnErr(err)
+	defer func() { panicOnErr(c.Close()) }()
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	f, err := c.WSSAsync("600588.SH", "sec_name", "")
+	panicOnErr(err)
+	if err := c.Login("mallory", "secret"); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("expected ErrLoginFailed, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := f.Wait(ctx); err != ErrConnectionFailed {
+		t.Errorf("expected %v, got %v", ErrConnectionFailed, err)
+	}
+	select {
+	case evt := <-subs.Events():
+		if evt.Kind != EventDisconnected || evt.Err != ErrConnectionFailed {
+			t.Errorf("unexpected event: %v", evt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout without event")
+	}
+
+	// the subscription is reissued once logged in again
+	panicOnErr(c.Login("alice", "secret"))
+	if reqs := fake.Requests(); len(reqs) != 3 || reqs[2].Method != "wsq" {
+		t.Errorf("expected the subscription to be reissued, got %v", reqs)
+	}
+}
+
+func TestFakeWSQ(t *testing.T) {
+	c, fake := newFakeClient(t)
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+
+	reqs := fake.Requests()
+	if len(reqs) != 1 || reqs[0].Method != "wsq" || reqs[0].Args[2] != ";REALTIME=Y" {
+		t.Fatalf("unexpected requests: %v", reqs)
+	}
+
+	fake.Push(reqs[0].ID, &FakeResult{
+		Codes:  []string{"600588.SH"},
+		Fields: []string{"rt_last"},
+		Times:  []time.Time{time.Now()},
+		Data:   []interface{}{10.5},
+	})
+	select {
+	case data := <-subs.C():
+		if len(data) != 1 || data[0].Values[0] != 10.5 {
+			t.Errorf("unexpected data: %v", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout without message")
+	}
+
+	panicOnErr(subs.Close())
+	if reqs = fake.Requests(); !reqs[0].Canceled {
+		t.Error("expected the request to be canceled")
+	}
+
+	subs, err = c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	panicOnErr(c.Close())
+	if _, ok := <-subs.C(); ok {
+		t.Error("expected the channel to be closed")
+	}
+	if err := subs.Close(); err != ErrClosing {
+		t.Errorf("expected ErrClosing, got %v", err)
+	}
+}
+
+func TestFakeContext(t *testing.T) {
+	c, fake := newFakeClient(t)
+	defer func() { panicOnErr(c.Close()) }()
+
+	// a request never answered
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.WSSContext(ctx, "600588.SH", "sec_name", "")
+	reqs := fake.Requests()
+	if len(reqs) != 1 || reqs[0].Method != "wss" || !reqs[0].Canceled {
+		t.Fatalf("expected a canceled wss request, got %v", reqs)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "request 1") {
+		t.Errorf("expected deadline exceeded of request 1, got %v", err)
+	}
+
+	// a context done already issues nothing
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err = c.WSSContext(ctx, "600588.SH", "sec_name", ""); err != context.Canceled {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	if _, err = c.WSQContext(ctx, "600588.SH", "rt_last", ""); err != context.Canceled {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	if reqs = fake.Requests(); len(reqs) != 1 {
+		t.Errorf("expected no more requests, got %v", reqs)
+	}
+
+	// a request answered by an event
+	go func() {
+		for len(fake.Requests()) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		fake.Push(2, &FakeResult{
+			Codes:  []string{"600588.SH"},
+			Fields: []string{"sec_name"},
+			Times:  []time.Time{time.Now()},
+			Data:   []interface{}{"用友网络"},
+		})
+	}()
+	data, err := c.WSSContext(context.Background(), "600588.SH", "sec_name", "")
+	panicOnErr(err)
+	if len(data) != 1 || data[0].Values[0] != "用友网络" {
+		t.Errorf("unexpected data: %v", data)
+	}
+
+	// a subscription bound to ctx
+	ctx, cancel = context.WithCancel(context.Background())
+	subs, err := c.WSQContext(ctx, "600588.SH", "rt_last", "")
+	panicOnErr(err)
+	cancel()
+	select {
+	case _, ok := <-subs.C():
+		if ok {
+			t.Error("expected the channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout without closing the subscription")
+	}
+	if err := subs.Close(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	if reqs = fake.Requests(); !reqs[2].Canceled {
+		t.Error("expected the subscription to be canceled")
+	}
+
+	// the deadline is reached while backing off
+	go func() {
+		for len(fake.Requests()) < 4 {
+			time.Sleep(time.Millisecond)
+		}
+		fake.Push(4, &FakeResult{ErrCode: -40521010})
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.WSSContext(ctx, "600588.SH", "sec_name", "")
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "request 4") {
+		t.Errorf("expected deadline exceeded of request 4, got %v", err)
+	}
+}
+
+func TestFakeContextStalled(t *testing.T) {
+	c, fake := newFakeClient(t)
+	defer func() { panicOnErr(c.Close()) }()
+
+	// nobody reads from it, ioloop blocks on it
+	stalled, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	defer stalled.Close()
+	for i := 1; i <= 3; i++ {
+		fake.Push(stalled.reqid, &FakeResult{
+			Codes:  []string{"600588.SH"},
+			Fields: []string{"rt_last"},
+			Times:  []time.Time{time.Now()},
+			Data:   []interface{}{float64(i)},
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	// the deadline is enforced
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.WSSContext(ctx, "600588.SH", "sec_name", ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("deadline is not enforced, returned after %v", elapsed)
+	}
+
+	// the subscription is closed once ctx is done
+	ctx, cancel = context.WithCancel(context.Background())
+	subs, err := c.WSQContext(ctx, "000001.SZ", "rt_last", "")
+	panicOnErr(err)
+	cancel()
+	select {
+	case <-subs.Done():
+	case <-time.After(time.Second):
+		t.Fatal("timeout without closing the subscription")
+	}
+	if err := subs.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+}
+
+func TestFakeAsync(t *testing.T) {
+	c, fake := newFakeClient(t)
+	defer func() { panicOnErr(c.Close()) }()
+
+	var futures []*DataFuture
+	for _, code := range []string{"600588.SH", "000001.SZ", "600000.SH"} {
+		f, err := c.WSSAsync(code, "sec_name", "")
+		panicOnErr(err)
+		futures = append(futures, f)
+	}
+	if !fake.asyn {
+		t.Error("expected asynchronous mode to be enabled")
+	}
+
+	// complete out of order
+	fake.Emit(2, futures[2].ReqID(), -40522007)
+	fake.Push(futures[0].ReqID(), &FakeResult{
+		Codes:  []string{"600588.SH"},
+		Fields: []string{"sec_name"},
+		Times:  []time.Time{time.Now()},
+		Data:   []interface{}{"用友网络"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := futures[2].Wait(ctx); !errors.Is(err, ErrUnsupportedField) {
+		t.Errorf("expected ErrUnsupportedField, got %v", err)
+	}
+	data, err := futures[0].Wait(ctx)
+	panicOnErr(err)
+	if len(data) != 1 || data[0].Values[0] != "用友网络" {
+		t.Errorf("unexpected data: %v", data)
+	}
+
+	panicOnErr(futures[1].Cancel())
+	select {
+	case <-futures[1].Done():
+	default:
+		t.Error("expected the future to be done")
+	}
+	if _, err := futures[1].Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+	if reqs := fake.Requests(); !reqs[1].Canceled || reqs[0].Canceled {
+		t.Errorf("expected only the second request to be canceled, got %v", reqs)
+	}
+}
+
+func TestFakeReconnect(t *testing.T) {
+	fake := NewFake()
+	var logins int32
+	fake.Handle("start_cpp", func(args []interface{}) *FakeResult {
+		atomic.AddInt32(&logins, 1)
+		fake.SetConnectionState(0)
+		return nil
+	})
+	c, err := New(WithFake(fake), WithReconnect(10*time.Millisecond, RetryPolicy{InitialBackoff: time.Millisecond, Multiplier: 1}))
+	panicOnErr(err)
+	defer func() { panicOnErr(c.Close()) }()
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+
+	resubscribed := func(n int) FakeRequest {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if reqs := fake.Requests(); len(reqs) == n {
+				return reqs[n-1]
+			}
+			time.Sleep(time.Millisecond)
+		}
+		t.Fatalf("timeout without resubscribing, got %v", fake.Requests())
+		return FakeRequest{}
+	}
+	received := func(price float64, reqid uint64) {
+		fake.Push(reqid, &FakeResult{
+			Codes:  []string{"600588.SH"},
+			Fields: []string{"rt_last"},
+			Times:  []time.Time{time.Now()},
+			Data:   []interface{}{price},
+		})
+		select {
+		case data := <-subs.C():
+			if data[0].Values[0] != price {
+				t.Errorf("unexpected data: %v", data)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout without message")
+		}
+	}
+
+	notified := func(kind EventKind, reqid uint64) {
+		for {
+			select {
+			case evt := <-subs.Events():
+				// the error pushed by the server races with the supervisor
+				if evt.Kind == EventState {
+					continue
+				}
+				if evt.Kind != kind || evt.ReqID != reqid {
+					t.Errorf("expected %s of request %d, got %v", kind, reqid, evt)
+				}
+				if kind == EventDisconnected && evt.Err != ErrConnectionFailed {
+					t.Errorf("expected %v, got %v", ErrConnectionFailed, evt.Err)
+				}
+				return
+			case <-time.After(time.Second):
+				t.Fatalf("timeout without %s", kind)
+			}
+		}
+	}
+
+	// dropped connection
+	fake.SetConnectionState(1)
+	req := resubscribed(2)
+	if req.Method != "wsq" || req.Args[0] != "600588.SH" || req.Args[1] != "rt_last" {
+		t.Errorf("unexpected request: %v", req)
+	}
+	notified(EventDisconnected, fake.Requests()[0].ID)
+	notified(EventReissued, req.ID)
+	received(10.5, req.ID)
+
+	// logged in elsewhere
+	fake.Emit(2, req.ID, -40520013)
+	notified(EventDisconnected, req.ID)
+	req = resubscribed(3)
+	notified(EventReissued, req.ID)
+	received(10.6, req.ID)
+
+	if n := atomic.LoadInt32(&logins); n != 3 {
+		t.Errorf("expected 3 logins, got %d", n)
+	}
+
+	// not supervised after logging out
+	panicOnErr(c.Logout())
+	fake.SetConnectionState(1)
+	if evt := <-subs.Events(); evt.Kind != EventDisconnected || evt.Err != ErrLoggedOut {
+		t.Errorf("unexpected event: %v", evt)
+	}
+	session := func() uint64 {
+		c.io.RLock()
+		defer c.io.RUnlock()
+		return c.super.session
+	}
+	loggedOut := session()
+	time.Sleep(50 * time.Millisecond)
+	if n := session(); n != loggedOut {
+		t.Errorf("expected the session to stay ended, %d ended since logging out", n-loggedOut)
+	}
+	if n := atomic.LoadInt32(&logins); n != 3 {
+		t.Errorf("expected no more logins, got %d", n)
+	}
+
+	panicOnErr(subs.Close())
+	if reqs := fake.Requests(); !reqs[2].Canceled {
+		t.Error("expected the reissued request to be canceled")
+	}
+}
+
+func TestFakeLogin(t *testing.T) {
+	fake := NewFake()
+	var (
+		logins []string
+		stops  int32
+	)
+	fake.Handle("start_cpp", func(args []interface{}) *FakeResult {
+		logins = append(logins, args[0].(string))
+		return nil
+	})
+	fake.Handle("stop", func(args []interface{}) *FakeResult {
+		atomic.AddInt32(&stops, 1)
+		return nil
+	})
+	c, err := New(WithFake(fake), WithLogin("alice", "secret"), WithLanguage("EN"), WithLoginOptions("ShowMenu=No"))
+	panicOnErr(err)
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	panicOnErr(c.Login("bob", "secret2"))
+	expected := []string{
+		"UserName=alice;Password=secret;Language=EN;ShowMenu=No",
+		"UserName=bob;Password=secret2;Language=EN;ShowMenu=No",
+	}
+	if len(logins) != 2 || logins[0] != expected[0] || logins[1] != expected[1] {
+		t.Errorf("unexpected logins: %v", logins)
+	}
+	if reqs := fake.Requests(); len(reqs) != 2 || reqs[1].Method != "wsq" {
+		t.Errorf("expected the subscription to be reissued, got %v", reqs)
+	}
+
+	panicOnErr(c.Logout())
+	panicOnErr(c.Close())
+	if n := atomic.LoadInt32(&stops); n != 3 {
+		t.Errorf("expected 3 stops, got %d", n)
+	}
+	if _, ok := <-subs.C(); ok {
+		t.Error("expected the channel to be closed")
+	}
+}
+
+func TestFakeSubscriptionUpdate(t *testing.T) {
+	c, fake := newFakeClient(t)
+	defer func() { panicOnErr(c.Close()) }()
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+
+	last := func() FakeRequest {
+		reqs := fake.Requests()
+		for _, req := range reqs[:len(reqs)-1] {
+			if !req.Canceled {
+				t.Errorf("expected the former request to be canceled: %v", req)
+			}
+		}
+		return reqs[len(reqs)-1]
+	}
+
+	panicOnErr(subs.AddCodes("000001.SZ, 600588.sh"))
+	req := last()
+	if req.Args[0] != "600588.SH,000001.SZ" || req.Args[1] != "rt_last" {
+		t.Errorf("unexpected request: %v", req)
+	}
+	fake.Push(req.ID, &FakeResult{
+		Codes:  []string{"000001.SZ"},
+		Fields: []string{"rt_last"},
+		Times:  []time.Time{time.Now()},
+		Data:   []interface{}{12.1},
+	})
+	select {
+	case data := <-subs.C():
+		if data[0].WindCode != "000001.SZ" {
+			t.Errorf("unexpected data: %v", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout without message")
+	}
+
+	panicOnErr(subs.RemoveCodes("600588.SH"))
+	panicOnErr(subs.SetFields("rt_last,rt_vol"))
+	if req = last(); req.Args[0] != "000001.SZ" || req.Args[1] != "rt_last,rt_vol" {
+		t.Errorf("unexpected request: %v", req)
+	}
+
+	if err := subs.RemoveCodes("000001.SZ"); err != ErrNoCodes {
+		t.Errorf("expected ErrNoCodes, got %v", err)
+	}
+	panicOnErr(subs.Close())
+	if err := subs.AddCodes("600588.SH"); err != ErrSubscriptionClosed {
+		t.Errorf("expected ErrSubscriptionClosed, got %v", err)
+	}
+	last()
+}
+
+func TestFakeBackpressure(t *testing.T) {
+	c, fake := newFakeClient(t)
+	defer func() { panicOnErr(c.Close()) }()
+
+	subscribe := func(policy Backpressure, buffer int) *Subscription {
+		subs, err := c.WSQ("600588.SH", "rt_last", "", WithBackpressure(policy, buffer))
+		panicOnErr(err)
+		reqs := fake.Requests()
+		for i := 1; i <= 5; i++ {
+			fake.Push(reqs[len(reqs)-1].ID, &FakeResult{
+				Codes:  []string{"600588.SH"},
+				Fields: []string{"rt_last"},
+				Times:  []time.Time{time.Now()},
+				Data:   []interface{}{float64(i)},
+			})
+		}
+		return subs
+	}
+	receive := func(subs *Subscription) float64 {
+		select {
+		case data := <-subs.C():
+			return data[len(data)-1].Values[0].(float64)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout without message, %d dropped", subs.Dropped())
+		}
+		return 0
+	}
+	dropped := func(subs *Subscription, n uint64) {
+		deadline := time.Now().Add(time.Second)
+		for subs.Dropped() < n && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if subs.Dropped() != n {
+			t.Errorf("%s: expected %d dropped, got %d", subs.policy, n, subs.Dropped())
+		}
+	}
+
+	subs := subscribe(DropNewest, 2)
+	dropped(subs, 3)
+	if a, b := receive(subs), receive(subs); a != 1 || b != 2 {
+		t.Errorf("drop-newest: expected 1, 2, got %v, %v", a, b)
+	}
+	panicOnErr(subs.Close())
+
+	subs = subscribe(DropOldest, 2)
+	dropped(subs, 3)
+	if a, b := receive(subs), receive(subs); a != 4 || b != 5 {
+		t.Errorf("drop-oldest: expected 4, 5, got %v, %v", a, b)
+	}
+	panicOnErr(subs.Close())
+
+	subs = subscribe(Spill, 1)
+	for i := 1; i <= 5; i++ {
+		if v := receive(subs); v != float64(i) {
+			t.Errorf("spill: expected %d, got %v", i, v)
+		}
+	}
+	dropped(subs, 0)
+	panicOnErr(subs.Close())
+
+	subs = subscribe(CoalesceLatest, 1)
+	received := 1
+	for receive(subs) != 5 {
+		received++
+	}
+	dropped(subs, uint64(5-received))
+	panicOnErr(subs.Close())
+	if _, ok := <-subs.C(); ok {
+		t.Error("expected the channel to be closed")
+	}
+
+	// pushes carrying different fields are merged
+	subs = subscribe(CoalesceLatest, 1)
+	time.Sleep(20 * time.Millisecond)
+	reqs := fake.Requests()
+	for _, field := range []string{"rt_last", "rt_vol"} {
+		fake.Push(reqs[len(reqs)-1].ID, &FakeResult{
+			Codes:  []string{"600588.SH"},
+			Fields: []string{field},
+			Times:  []time.Time{time.Now()},
+			Data:   []interface{}{float64(100)},
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+	for {
+		var data []*WindData
+		select {
+		case data = <-subs.C():
+		case <-time.After(time.Second):
+			t.Fatal("timeout without merged message")
+		}
+		if len(data[0].Fields) == 1 {
+			continue
+		}
+		if d := data[0]; len(d.Fields) != 2 || d.Fields[0] != "rt_last" || d.Fields[1] != "rt_vol" || d.Values[0] != float64(100) || d.Values[1] != float64(100) {
+			t.Errorf("unexpected merged message: %v", d)
+		}
+		break
+	}
+	panicOnErr(subs.Close())
+}
+
+func TestFakeStalledSubscription(t *testing.T) {
+	c, fake := newFakeClient(t)
+
+	push := func(reqid uint64, n int) {
+		for i := 1; i <= n; i++ {
+			fake.Push(reqid, &FakeResult{
+				Codes:  []string{"600588.SH"},
+				Fields: []string{"rt_last"},
+				Times:  []time.Time{time.Now()},
+				Data:   []interface{}{float64(i)},
+			})
+		}
+	}
+
+	// nobody reads from them, ioloop blocks on the first one
+	stalled, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	push(stalled.reqid, 3)
+	another, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	push(another.reqid, 3)
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		subs, err := c.WSQ("000001.SZ", "rt_last", "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = subs.Close(); err != nil {
+			t.Error(err)
+		}
+		f, err := c.WSSAsync("600588.SH", "sec_name", "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if err = f.Cancel(); err != nil {
+			t.Error(err)
+		}
+		if err = stalled.Close(); err != nil {
+			t.Error(err)
+		}
+		if err = c.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("blocked by the stalled subscription")
+	}
+
+	// the buffered message is still there
+	if data, ok := <-stalled.C(); !ok || data[0].Values[0] != float64(1) {
+		t.Errorf("expected the first message, got %v", data)
+	}
+	if _, ok := <-stalled.C(); ok {
+		t.Error("expected the channel to be closed")
+	}
+	for range another.C() {
+	}
+	if err := another.Err(); err != ErrClosing {
+		t.Errorf("expected %v, got %v", ErrClosing, err)
+	}
+}
+
+func TestFakeRateLimit(t *testing.T) {
+	fake := NewFake()
+	c, err := New(WithFake(fake), WithRateLimit(RateLimit{}, RateLimit{Rate: 20, Burst: 1, FailFast: true}))
+	panicOnErr(err)
+	defer func() { panicOnErr(c.Close()) }()
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	if _, err := c.WSQ("000001.SZ", "rt_last", ""); err != ErrRateLimited {
+		t.Errorf("expected %v, got %v", ErrRateLimited, err)
+	}
+
+	// canceling waits for a token instead
+	panicOnErr(subs.Close())
+	if reqs := fake.Requests(); len(reqs) != 1 || !reqs[0].Canceled {
+		t.Errorf("expected the subscription to be canceled, got %v", reqs)
+	}
+}
+
+func TestFakeSubscriptionEvents(t *testing.T) {
+	c, fake := newFakeClient(t)
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	next := func() SubscriptionEvent {
+		select {
+		case evt := <-subs.Events():
+			return evt
+		case <-time.After(time.Second):
+			t.Fatal("timeout without event")
+		}
+		return SubscriptionEvent{}
+	}
+
+	fake.Emit(2, fake.Requests()[0].ID, -40522007)
+	if evt := next(); evt.Kind != EventState || evt.State != 2 || !errors.Is(evt.Err, ErrUnsupportedField) {
+		t.Errorf("unexpected event: %v", evt)
+	}
+	if subs.Err() != nil {
+		t.Errorf("expected no error while open, got %v", subs.Err())
+	}
+
+	panicOnErr(subs.AddCodes("000001.SZ"))
+	if evt := next(); evt.Kind != EventReissued || evt.ReqID != fake.Requests()[1].ID {
+		t.Errorf("unexpected event: %v", evt)
+	}
+
+	// notified once, logging in again only reissues it
+	panicOnErr(c.Logout())
+	if evt := next(); evt.Kind != EventDisconnected || evt.ReqID != fake.Requests()[1].ID || evt.Err != ErrLoggedOut {
+		t.Errorf("unexpected event: %v", evt)
+	}
+	panicOnErr(c.Login("user", "pass"))
+	if evt := next(); evt.Kind != EventReissued || evt.ReqID != fake.Requests()[2].ID {
+		t.Errorf("unexpected event: %v", evt)
+	}
+
+	panicOnErr(subs.Close())
+	<-subs.Done()
+	if evt := next(); evt.Kind != EventClosed || evt.Err != ErrSubscriptionClosed {
+		t.Errorf("unexpected event: %v", evt)
+	}
+	if _, ok := <-subs.Events(); ok {
+		t.Error("expected events to be closed")
+	}
+	if subs.Err() != ErrSubscriptionClosed {
+		t.Errorf("expected ErrSubscriptionClosed, got %v", subs.Err())
+	}
+
+	subs, err = c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	panicOnErr(c.Close())
+	<-subs.Done()
+	if subs.Err() != ErrClosing {
+		t.Errorf("expected ErrClosing, got %v", subs.Err())
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	fake := NewFake()
+	c, err := New(WithFake(fake), WithRecord(&buf))
+	panicOnErr(err)
+
+	now := time.Now()
+	fake.Handle("wss_syn", func(args []interface{}) *FakeResult {
+		return &FakeResult{
+			Codes:  []string{"600588.SH"},
+			Fields: []string{"sec_name"},
+			Times:  []time.Time{now},
+			Data:   []interface{}{"用友网络"},
+		}
+	})
+	_, err = c.WSS("600588.SH", "sec_name", "")
+	panicOnErr(err)
+	// wind returns NaN for missing values
+	fake.Handle("wss_syn", func(args []interface{}) *FakeResult {
+		return &FakeResult{
+			Codes:  []string{"600588.SH", "000001.SZ"},
+			Fields: []string{"pe_ttm"},
+			Times:  []time.Time{now},
+			Data:   []interface{}{math.NaN(), math.Inf(1)},
+		}
+	})
+	_, err = c.WSS("600588.SH,000001.SZ", "pe_ttm", "")
+	panicOnErr(err)
+
+	subs, err := c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	for i := 0; i < 3; i++ {
+		fake.Push(fake.Requests()[0].ID, &FakeResult{
+			Codes:  []string{"600588.SH"},
+			Fields: []string{"rt_last"},
+			Times:  []time.Time{now.Add(time.Duration(i) * time.Second)},
+			Data:   []interface{}{10.5 + float64(i)},
+		})
+		<-subs.C()
+	}
+	panicOnErr(c.Close())
+
+	c, err = New(WithReplay(&buf, 0))
+	panicOnErr(err)
+	defer func() { panicOnErr(c.Close()) }()
+
+	data, err := c.WSS("600588.SH", "sec_name", "")
+	panicOnErr(err)
+	if len(data) != 1 || data[0].Values[0] != "用友网络" || !data[0].UpdateTime.Equal(now) {
+		t.Errorf("unexpected replayed data: %v", data)
+	}
+	data, err = c.WSS("600588.SH,000001.SZ", "pe_ttm", "")
+	panicOnErr(err)
+	if len(data) != 2 || !math.IsNaN(data[0].Values[0].(float64)) || !math.IsInf(data[1].Values[0].(float64), 1) {
+		t.Errorf("unexpected replayed data: %v", data)
+	}
+
+	subs, err = c.WSQ("600588.SH", "rt_last", "")
+	panicOnErr(err)
+	for i := 0; i < 3; i++ {
+		select {
+		case data := <-subs.C():
+			if len(data) != 1 || data[0].Values[0] != 10.5+float64(i) {
+				t.Errorf("unexpected replayed quote #%d: %v", i, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout without replayed quote #%d", i)
+		}
+	}
+}
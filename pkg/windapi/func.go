@@ -26,6 +26,6 @@ func getCurrentThreadID() uint32 {
 	return 0
 }
 
-func postMessage0012(tid uint32) (res uintptr, err error) {
+func postThreadMessage(tid, msg uint32) (res uintptr, err error) {
 	return 1, errUnsupportedErr
 }
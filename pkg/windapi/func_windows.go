@@ -157,9 +157,12 @@ func getCurrentThreadID() uint32 {
 	return windows.GetCurrentThreadId()
 }
 
-func postMessage0012(tid uint32) (res uintptr, err error) {
-	moduser32, _ := syscall.LoadDLL("user32.dll")
-	postMessage, _ := moduser32.FindProc("PostThreadMessageW")
-	res, _, err = postMessage.Call(uintptr(tid), uintptr(0x0012), 0x0, 0x0)
+var procPostThreadMessage = windows.NewLazySystemDLL("user32.dll").NewProc("PostThreadMessageW")
+
+func postThreadMessage(tid, msg uint32) (res uintptr, err error) {
+	if err = procPostThreadMessage.Find(); err != nil {
+		return 0, err
+	}
+	res, _, err = procPostThreadMessage.Call(uintptr(tid), uintptr(msg), 0x0, 0x0)
 	return
 }
@@ -0,0 +1,272 @@
+package windapi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WSSAsync issues WSS as a request, and returns without waiting for its result
+func WSSAsync(codes, fields, options string) (*DataFuture, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSSAsync(codes, fields, options)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSDAsync issues WSD as a request, and returns without waiting for its result
+func WSDAsync(codes, fields string, begin, end time.Time, options string) (*SeriesFuture, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSDAsync(codes, fields, begin, end, options)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// EDBAsync issues EDB as a request, and returns without waiting for its result
+func EDBAsync(codes string, begin, end time.Time, options string) (*SeriesFuture, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.EDBAsync(codes, begin, end, options)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSETAsync issues WSET as a request, and returns without waiting for its result
+func WSETAsync(report, options string) (*TableFuture, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSETAsync(report, options)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// Future is a request in flight, it's completed by ioloop once wind notifies its result through the event sink
+type Future struct {
+	wind  *windObj
+	reqid uint64
+
+	once sync.Once
+	done chan struct{}
+	raw  *rawData
+	err  error
+}
+
+// ReqID returns the request id assigned by wind
+func (f *Future) ReqID() uint64 {
+	return f.reqid
+}
+
+// Done returns a channel which is closed once the result is ready
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel cancels the request if it's still in flight, the future then completes with context.Canceled
+func (f *Future) Cancel() error {
+	return f.cancel(context.Canceled)
+}
+
+// wait waits for the result until ctx is done, in which case the request is canceled
+func (f *Future) wait(ctx context.Context) (*rawData, error) {
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		if f.wind.ctx.Err() != nil {
+			// ioloop cleans up when wind is closed
+			f.finish(nil, ErrClosing)
+		} else if err := f.cancel(ctx.Err()); err != nil {
+			f.wind.log.Warningf("(wind) failed to cancel request %d: %v", f.reqid, err)
+		}
+		// ioloop may be completing it concurrently
+		<-f.done
+	}
+	return f.raw, f.err
+}
+
+// cancel cancels the request, and completes the future with reason along with the request id
+func (f *Future) cancel(reason error) error {
+	wind := f.wind
+	wind.io.Lock()
+	_, pending := wind.io.futures[f.reqid]
+	delete(wind.io.futures, f.reqid)
+	wind.io.Unlock()
+	if !pending {
+		return nil
+	}
+
+	err := wind.cancel(f.reqid)
+	f.finish(nil, fmt.Errorf("wind: request %d: %w", f.reqid, reason))
+	return err
+}
+
+// complete reads the result when the event of the request arrives, it's called by ioloop
+func (f *Future) complete(evt event) {
+	var (
+		raw *rawData
+		err error
+	)
+	if evt.State == 1 {
+		if raw, err = f.wind.readRaw(f.reqid); err == nil {
+			err = parseErr(raw.errCode)
+		}
+	} else if err = parseErr(evt.ErrCode); err == nil {
+		err = fmt.Errorf("wind: request %d, unrecognized state code: %d", f.reqid, evt.State)
+	}
+	f.wind.watch(err)
+
+	f.wind.io.Lock()
+	delete(f.wind.io.futures, f.reqid)
+	f.wind.io.Unlock()
+	f.finish(raw, err)
+}
+
+// finish sets the result once, later results are dropped
+func (f *Future) finish(raw *rawData, err error) {
+	f.once.Do(func() {
+		f.raw, f.err = raw, err
+		close(f.done)
+	})
+}
+
+// DataFuture is a future of WindData, e.g. the result of WSSAsync
+type DataFuture struct {
+	*Future
+}
+
+// Wait waits for the result until ctx is done, in which case the request is canceled
+func (f *DataFuture) Wait(ctx context.Context) ([]*WindData, error) {
+	raw, err := f.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseRawData(raw)
+}
+
+// SeriesFuture is a future of time series, e.g. the result of WSDAsync
+type SeriesFuture struct {
+	*Future
+}
+
+// Wait waits for the result until ctx is done, in which case the request is canceled
+func (f *SeriesFuture) Wait(ctx context.Context) ([]*Series, error) {
+	raw, err := f.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := parseRawData(raw)
+	if err != nil {
+		return nil, err
+	}
+	return toSeries(data), nil
+}
+
+// TableFuture is a future of a table, e.g. the result of WSETAsync
+type TableFuture struct {
+	*Future
+}
+
+// Wait waits for the result until ctx is done, in which case the request is canceled
+func (f *TableFuture) Wait(ctx context.Context) (*Table, error) {
+	raw, err := f.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseRawTable(raw)
+}
+
+// WSSAsync issues WSS as a request, many requests can be in flight at once,
+// unlike WSS it's never retried
+func (wind *windObj) WSSAsync(codes, fields, options string) (*DataFuture, error) {
+	f, err := wind.async("wss", codes, fields, options)
+	if err != nil {
+		return nil, err
+	}
+	return &DataFuture{f}, nil
+}
+
+// WSDAsync issues WSD as a request, many requests can be in flight at once,
+// unlike WSD it's never retried
+func (wind *windObj) WSDAsync(codes, fields string, begin, end time.Time, options string) (*SeriesFuture, error) {
+	f, err := wind.async("wsd", codes, fields, begin.Format(dateLayout), end.Format(dateLayout), options)
+	if err != nil {
+		return nil, err
+	}
+	return &SeriesFuture{f}, nil
+}
+
+// EDBAsync issues EDB as a request, many requests can be in flight at once,
+// unlike EDB it's never retried
+func (wind *windObj) EDBAsync(codes string, begin, end time.Time, options string) (*SeriesFuture, error) {
+	f, err := wind.async("edb", codes, begin.Format(dateLayout), end.Format(dateLayout), options)
+	if err != nil {
+		return nil, err
+	}
+	return &SeriesFuture{f}, nil
+}
+
+// WSETAsync issues WSET as a request, many requests can be in flight at once,
+// unlike WSET it's never retried
+func (wind *windObj) WSETAsync(report, options string) (*TableFuture, error) {
+	f, err := wind.async("wset", report, options)
+	if err != nil {
+		return nil, err
+	}
+	return &TableFuture{f}, nil
+}
+
+// async issues a request paced by limits.query, like the synchronous version of method
+func (wind *windObj) async(method string, args ...interface{}) (*Future, error) {
+	if err := wind.limits.query.wait(wind.ctx); err != nil {
+		return nil, err
+	}
+	return wind.request(method, args...)
+}
+
+// ensureAsyn enables asynchronous mode once, it's enabled again after reconnecting
+func (wind *windObj) ensureAsyn() error {
+	wind.asyn.Lock()
+	defer wind.asyn.Unlock()
+	if wind.asyn.enabled {
+		return nil
+	}
+	if err := wind.enableAsyn(); err != nil {
+		return err
+	}
+	wind.asyn.enabled = true
+	return nil
+}
+
+// request issues an asynchronous method, e.g. wss, the returned future is completed by ioloop,
+// asynchronous mode is enabled on the first request
+func (wind *windObj) request(method string, args ...interface{}) (*Future, error) {
+	wind.startIO()
+	if err := wind.ensureAsyn(); err != nil {
+		return nil, err
+	}
+
+	// register before ioloop sees the event of reqid
+	wind.io.Lock()
+	defer wind.io.Unlock()
+	reqid, errCode, err := wind.backend.request(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err = parseErr(errCode); err != nil {
+		return nil, err
+	}
+
+	f := &Future{
+		wind:  wind,
+		reqid: reqid,
+		done:  make(chan struct{}),
+	}
+	wind.io.futures[reqid] = f
+	return f, nil
+}
@@ -0,0 +1,87 @@
+package windapi
+
+// Login switches to the given account at runtime, options like the language given to Open are kept.
+// Subscriptions are reissued under the account, which is also used when reconnecting.
+func (wind *windObj) Login(username, password string) error {
+	wind.login.Lock()
+	defer wind.login.Unlock()
+
+	o := *wind.login.o
+	o.username, o.password = username, password
+	if err := wind.restart(&o); err != nil {
+		return err
+	}
+	wind.login.o = &o
+	wind.login.loggedOut = false
+
+	return wind.resubscribe()
+}
+
+// Logout ends the session, subscriptions go silent after EventDisconnected, and the connection is not supervised until Login
+func (wind *windObj) Logout() error {
+	wind.login.Lock()
+	defer wind.login.Unlock()
+
+	wind.login.loggedOut = true
+	if err := wind.stop(); err != nil {
+		return err
+	}
+	wind.endSession(ErrLoggedOut)
+	return nil
+}
+
+// relogin starts a new session using the current account, unless it has logged out
+func (wind *windObj) relogin() error {
+	wind.login.Lock()
+	defer wind.login.Unlock()
+
+	if wind.login.loggedOut {
+		return ErrLoggedOut
+	}
+	return wind.restart(wind.login.o)
+}
+
+// restart stops the current session, and starts a new one logged in with o,
+// the current session ends even if the new one fails to start
+func (wind *windObj) restart(o *options) error {
+	// the former session may be still alive, e.g. when logged in elsewhere
+	if err := wind.stop(); err != nil {
+		wind.log.Warningf("(wind) failed to stop the former session: %v", err)
+	}
+	err := wind.start(o.startOptions(), "", o.startTimeoutMillis())
+	// requests in flight are lost along with the former session, whether a new one starts or not
+	wind.endSession(ErrConnectionFailed)
+	if err != nil {
+		wind.log.Warningf("(wind) failed to log in: %v", err)
+		return err
+	}
+
+	wind.asyn.Lock()
+	if wind.asyn.enabled {
+		if err := wind.enableAsyn(); err != nil {
+			wind.log.Warningf("(wind) failed to enable asynchronous mode: %v", err)
+			wind.asyn.enabled = false
+		}
+	}
+	wind.asyn.Unlock()
+	return nil
+}
+
+// endSession fails requests in flight with err, and marks subscriptions to be reissued,
+// subscriptions of the ending session are notified with EventDisconnected
+func (wind *windObj) endSession(err error) {
+	wind.io.Lock()
+	defer wind.io.Unlock()
+	for reqid, subs := range wind.io.ds {
+		// a former request of a subscription may be still mapped, and subscriptions of former sessions have been notified
+		if subs.closed || subs.reqid != reqid || subs.session != wind.super.session {
+			continue
+		}
+		subs.report(SubscriptionEvent{Kind: EventDisconnected, ReqID: reqid, Err: err})
+	}
+	wind.super.session++
+	for reqid, f := range wind.io.futures {
+		f.finish(nil, err)
+		delete(wind.io.futures, reqid)
+	}
+}
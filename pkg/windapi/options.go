@@ -0,0 +1,130 @@
+package windapi
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// Option configures the api when it is opened
+type Option func(*options)
+
+type options struct {
+	username string
+	password string
+	language string
+	// loginOptions are passed to start_cpp as is
+	loginOptions string
+
+	startTimeout time.Duration
+
+	logger Logger
+
+	// newBackend creates the backend, wind's COM object is used if nil
+	newBackend func(o *options) (backend, error)
+	record     io.Writer
+
+	queryLimit     RateLimit
+	subscribeLimit RateLimit
+
+	// reconnect is disabled if reconnectInterval <= 0
+	reconnectInterval time.Duration
+	reconnectBackoff  RetryPolicy
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		startTimeout: 5 * time.Second,
+		logger:       klogLogger{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// startOptions returns login options passed to wind's start
+func (o *options) startOptions() string {
+	var opts []string
+	if o.username != "" {
+		opts = append(opts, "UserName="+o.username, "Password="+o.password)
+	}
+	if o.language != "" {
+		opts = append(opts, "Language="+o.language)
+	}
+	if o.loginOptions != "" {
+		opts = append(opts, o.loginOptions)
+	}
+	return strings.Join(opts, ";")
+}
+
+func (o *options) startTimeoutMillis() int32 {
+	return int32(o.startTimeout / time.Millisecond)
+}
+
+// WithLogin logs in using the given account, instead of the one logged in the terminal
+func WithLogin(username, password string) Option {
+	return func(o *options) {
+		o.username = username
+		o.password = password
+	}
+}
+
+// WithLoginOptions passes options to wind's start as is, e.g. to log in without WIM,
+// they are appended to the ones given by WithLogin and WithLanguage
+func WithLoginOptions(opts string) Option {
+	return func(o *options) {
+		o.loginOptions = opts
+	}
+}
+
+// WithLanguage sets the language of wind's messages, e.g. EN or CN
+func WithLanguage(lang string) Option {
+	return func(o *options) {
+		o.language = lang
+	}
+}
+
+// WithStartTimeout sets the timeout of starting wind's api, it's 5s by default
+func WithStartTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.startTimeout = timeout
+	}
+}
+
+// WithLogger sets the logger, it logs to klog by default
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithRateLimit paces calls to wind, query limits synchronous calls like WSS,
+// subscribe limits calls of subscriptions, i.e. wsq, readdata and cancelRequest.
+// Calls are not limited by default.
+func WithRateLimit(query, subscribe RateLimit) Option {
+	return func(o *options) {
+		o.queryLimit = query
+		o.subscribeLimit = subscribe
+	}
+}
+
+// DefaultReconnectBackoff is a backoff suitable for WithReconnect
+var DefaultReconnectBackoff = RetryPolicy{
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// WithReconnect sets how the connection is supervised, it's checked every interval,
+// and once it drops, the api logs in again and resubscribes every subscription,
+// attempts are spaced by backoff until they succeed, MaxAttempts of backoff is ignored.
+// The connection is not supervised unless it's given with a positive interval,
+// e.g. WithReconnect(5*time.Second, DefaultReconnectBackoff).
+func WithReconnect(interval time.Duration, backoff RetryPolicy) Option {
+	return func(o *options) {
+		o.reconnectInterval = interval
+		o.reconnectBackoff = backoff
+	}
+}
@@ -0,0 +1,65 @@
+package windapi
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PortfolioHolding is a position uploaded to wind's PMS
+type PortfolioHolding struct {
+	TradeDate time.Time
+	WindCode  string
+	Quantity  float64
+	CostPrice float64
+}
+
+// WPF returns a report of a PMS portfolio as a table, view is the report's view, e.g. "PMS.PortfolioDaily"
+func WPF(portfolio, view, options string, opts ...CallOption) (*Table, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WPF(portfolio, view, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WUPF uploads holdings to a PMS portfolio
+func WUPF(portfolio string, holdings []PortfolioHolding, options string, opts ...CallOption) error {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WUPF(portfolio, holdings, options, opts...)
+	}
+	return ErrAPINotOpen
+}
+
+// WPF returns a report of a PMS portfolio as a table
+func (wind *windObj) WPF(portfolio, view, options string, opts ...CallOption) (*Table, error) {
+	return wind.getTable(opts, "wpf_syn", portfolio, view, options)
+}
+
+// WUPF uploads holdings to a PMS portfolio, it's not retried unless WithRetry is given,
+// for holdings may be uploaded twice if the server has accepted them before a timeout
+func (wind *windObj) WUPF(portfolio string, holdings []PortfolioHolding, options string, opts ...CallOption) error {
+	if len(holdings) == 0 {
+		return errors.New("wind: no holdings to upload")
+	}
+	dates, codes, quantities, prices := joinHoldings(holdings)
+	opts = append(append([]CallOption(nil), noRetry...), opts...)
+	_, err := wind.getTable(opts, "wupf_syn", portfolio, dates, codes, quantities, prices, options)
+	return err
+}
+
+// joinHoldings formats holdings into comma separated lists of columns
+func joinHoldings(holdings []PortfolioHolding) (dates, codes, quantities, prices string) {
+	var cols [4][]string
+	for _, h := range holdings {
+		cols[0] = append(cols[0], h.TradeDate.Format("20060102"))
+		cols[1] = append(cols[1], h.WindCode)
+		cols[2] = append(cols[2], strconv.FormatFloat(h.Quantity, 'f', -1, 64))
+		cols[3] = append(cols[3], strconv.FormatFloat(h.CostPrice, 'f', -1, 64))
+	}
+	return strings.Join(cols[0], ","), strings.Join(cols[1], ","), strings.Join(cols[2], ","), strings.Join(cols[3], ",")
+}
@@ -0,0 +1,106 @@
+package windapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned if a call can not be paced within its context or fails fast
+var ErrRateLimited = errors.New("wind: rate limited")
+
+// RateLimit is a token bucket budget of calls to wind
+type RateLimit struct {
+	// Rate is the number of calls per second, calls are not limited if <= 0
+	Rate float64
+	// Burst is the maximum number of calls at once, at least 1
+	Burst int
+	// FailFast makes calls fail with ErrRateLimited instead of blocking for a token,
+	// except readdata and cancelRequest of subscriptions and requests, which always wait
+	FailFast bool
+}
+
+// limiter is a token bucket, a nil limiter allows everything
+type limiter struct {
+	sync.Mutex
+
+	rate     float64
+	burst    float64
+	failFast bool
+
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:     limit.Rate,
+		burst:    burst,
+		failFast: limit.FailFast,
+		tokens:   burst,
+		last:     time.Now(),
+	}
+}
+
+// wait takes a token, it blocks until the token is available or ctx is done,
+// and fails fast with ErrRateLimited if the token can not be available before ctx's deadline
+func (l *limiter) wait(ctx context.Context) error {
+	return l.take(ctx, l != nil && l.failFast)
+}
+
+// waitAlways takes a token like wait, but never fails fast,
+// it's used by calls which can not be given up, e.g. reading data of an event already arrived or canceling a request
+func (l *limiter) waitAlways(ctx context.Context) error {
+	return l.take(ctx, false)
+}
+
+func (l *limiter) take(ctx context.Context, failFast bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l == nil {
+		return nil
+	}
+
+	l.Lock()
+	now := time.Now()
+	if l.tokens += now.Sub(l.last).Seconds() * l.rate; l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	// reserve a token in advance
+	l.tokens--
+	if l.tokens >= 0 {
+		l.Unlock()
+		return nil
+	}
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); failFast || (ok && deadline.Before(now.Add(delay))) {
+		l.tokens++
+		l.Unlock()
+		return ErrRateLimited
+	}
+	l.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// give back the reservation
+		l.Lock()
+		l.tokens++
+		l.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,503 @@
+package windapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithRecord records the session to w as JSON lines, i.e. every call to wind with its raw result,
+// and every event from the event sink, login options are not recorded.
+// The recorded session can be replayed using WithReplay.
+func WithRecord(w io.Writer) Option {
+	return func(o *options) {
+		o.record = w
+	}
+}
+
+// WithReplay replays a session recorded by WithRecord instead of talking to wind.
+// Calls are answered by the recorded results in order, events are sent with their original timing,
+// scaled by speed, e.g. 10 replays 10x faster, events are sent without delay if speed <= 0.
+// Note that values of data are restored from JSON, e.g. integers become float64.
+func WithReplay(r io.Reader, speed float64) Option {
+	return func(o *options) {
+		o.newBackend = func(o *options) (backend, error) {
+			return newReplayer(r, speed, o.logger)
+		}
+	}
+}
+
+// operations in a recorded session
+const (
+	opStart    = "start"
+	opStop     = "stop"
+	opState    = "state"
+	opAsyn     = "asyn"
+	opQuery    = "query"
+	opRequest  = "request"
+	opReaddata = "readdata"
+	opCancel   = "cancel"
+	opEvent    = "event"
+)
+
+// recordEntry is a line of a recorded session
+type recordEntry struct {
+	Time    time.Duration `json:"t"` // since the session started
+	Op      string        `json:"op"`
+	Method  string        `json:"method,omitempty"`
+	Args    []interface{} `json:"args,omitempty"`
+	ReqID   uint64        `json:"reqid,omitempty"`
+	State   int32         `json:"state,omitempty"`
+	ErrCode int32         `json:"errcode,omitempty"`
+	Result  *recordResult `json:"result,omitempty"`
+	Err     string        `json:"err,omitempty"`
+}
+
+type recordResult struct {
+	Codes  []string      `json:"codes"`
+	Fields []string      `json:"fields"`
+	Times  []time.Time   `json:"times"`
+	Data   []interface{} `json:"data"`
+}
+
+func (e *recordEntry) setErr(err error) {
+	if err != nil {
+		e.Err = err.Error()
+	}
+}
+
+func (e *recordEntry) error() error {
+	if e.Err != "" {
+		return errors.New(e.Err)
+	}
+	return nil
+}
+
+func (e *recordEntry) setRaw(raw *rawData) {
+	if raw != nil {
+		e.ErrCode = raw.errCode
+		e.Result = &recordResult{Codes: raw.codes, Fields: raw.fields, Times: raw.times, Data: encodeValues(raw.data)}
+	}
+}
+
+func (e *recordEntry) raw() (*rawData, error) {
+	if err := e.error(); err != nil {
+		return nil, err
+	}
+	raw := &rawData{errCode: e.ErrCode}
+	if e.Result != nil {
+		raw.codes, raw.fields, raw.times, raw.data = e.Result.Codes, e.Result.Fields, e.Result.Times, decodeValues(e.Result.Data)
+	}
+	return raw, nil
+}
+
+// nonFinite tags a float which JSON can not represent, e.g. NaN returned by wind for missing values
+type nonFinite struct {
+	Float string `json:"float"`
+}
+
+// encodeValues replaces NaN and infinities in data with nonFinite, data is copied only if there's any
+func encodeValues(data []interface{}) []interface{} {
+	var values []interface{}
+	for i, v := range data {
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case float32:
+			f = float64(v)
+		default:
+			continue
+		}
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			continue
+		}
+		if values == nil {
+			values = append([]interface{}(nil), data...)
+		}
+		values[i] = nonFinite{Float: strconv.FormatFloat(f, 'g', -1, 64)}
+	}
+	if values == nil {
+		return data
+	}
+	return values
+}
+
+// decodeValues restores nonFinite in data decoded from JSON
+func decodeValues(data []interface{}) []interface{} {
+	for i, v := range data {
+		m, ok := v.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			continue
+		}
+		if s, ok := m["float"].(string); ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				data[i] = f
+			}
+		}
+	}
+	return data
+}
+
+// recorder records calls to and events from a backend
+type recorder struct {
+	backend
+
+	mu    sync.Mutex
+	enc   *json.Encoder
+	begin time.Time
+	err   error
+
+	C    chan event
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newRecorder(b backend, w io.Writer) *recorder {
+	r := &recorder{
+		backend: b,
+		enc:     json.NewEncoder(w),
+		begin:   time.Now(),
+		C:       make(chan event, 1),
+		done:    make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer close(r.C)
+		for evt := range b.events() {
+			r.write(&recordEntry{Op: opEvent, ReqID: uint64(evt.RequestID), State: evt.State, ErrCode: evt.ErrCode})
+			// keep draining events after closing, so that the backend is never blocked
+			select {
+			case r.C <- evt:
+			case <-r.done:
+			}
+		}
+	}()
+
+	return r
+}
+
+func (r *recorder) write(e *recordEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.Time = time.Since(r.begin)
+	if err := r.enc.Encode(e); err != nil && r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *recorder) start(option1, option2 string, timeout int32) (int32, error) {
+	ec, err := r.backend.start(option1, option2, timeout)
+	e := &recordEntry{Op: opStart, ErrCode: ec}
+	e.setErr(err)
+	r.write(e)
+	return ec, err
+}
+
+func (r *recorder) stop() (int32, error) {
+	ec, err := r.backend.stop()
+	e := &recordEntry{Op: opStop, ErrCode: ec}
+	e.setErr(err)
+	r.write(e)
+	return ec, err
+}
+
+func (r *recorder) connectionState() (int32, error) {
+	state, err := r.backend.connectionState()
+	e := &recordEntry{Op: opState, State: state}
+	e.setErr(err)
+	r.write(e)
+	return state, err
+}
+
+func (r *recorder) enableAsyn() error {
+	err := r.backend.enableAsyn()
+	e := &recordEntry{Op: opAsyn}
+	e.setErr(err)
+	r.write(e)
+	return err
+}
+
+func (r *recorder) query(method string, args ...interface{}) (*rawData, error) {
+	raw, err := r.backend.query(method, args...)
+	e := &recordEntry{Op: opQuery, Method: method, Args: args}
+	e.setRaw(raw)
+	e.setErr(err)
+	r.write(e)
+	return raw, err
+}
+
+func (r *recorder) request(method string, args ...interface{}) (uint64, int32, error) {
+	reqid, ec, err := r.backend.request(method, args...)
+	e := &recordEntry{Op: opRequest, Method: method, Args: args, ReqID: reqid, ErrCode: ec}
+	e.setErr(err)
+	r.write(e)
+	return reqid, ec, err
+}
+
+func (r *recorder) readdata(reqid uint64) (*rawData, error) {
+	raw, err := r.backend.readdata(reqid)
+	e := &recordEntry{Op: opReaddata, ReqID: reqid}
+	e.setRaw(raw)
+	e.setErr(err)
+	r.write(e)
+	return raw, err
+}
+
+func (r *recorder) cancel(reqid uint64) error {
+	err := r.backend.cancel(reqid)
+	e := &recordEntry{Op: opCancel, ReqID: reqid}
+	e.setErr(err)
+	r.write(e)
+	return err
+}
+
+func (r *recorder) events() <-chan event {
+	return r.C
+}
+
+func (r *recorder) close() error {
+	close(r.done)
+	err := r.backend.close()
+	r.wg.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil && r.err != nil {
+		err = fmt.Errorf("wind: failed to record, %v", r.err)
+	}
+	return err
+}
+
+// replayer answers calls with a recorded session, and sends recorded events
+type replayer struct {
+	mu      sync.Mutex
+	entries []*recordEntry
+	used    []bool
+
+	// requests are replayed requests, with the time they are replayed
+	requests map[uint64]time.Time
+	// recorded are the recorded time of requests
+	recorded map[uint64]time.Duration
+	notify   chan struct{}
+
+	C    chan event
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	log Logger
+}
+
+func newReplayer(rd io.Reader, speed float64, log Logger) (*replayer, error) {
+	r := &replayer{
+		requests: make(map[uint64]time.Time),
+		recorded: make(map[uint64]time.Duration),
+		notify:   make(chan struct{}),
+		C:        make(chan event, 1),
+		done:     make(chan struct{}),
+		log:      log,
+	}
+
+	var events []*recordEntry
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		e := new(recordEntry)
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("wind: invalid recorded session, %v", err)
+		}
+		switch e.Op {
+		case opEvent:
+			events = append(events, e)
+		case opRequest:
+			r.recorded[e.ReqID] = e.Time
+			fallthrough
+		default:
+			r.entries = append(r.entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	r.used = make([]bool, len(r.entries))
+
+	r.wg.Add(1)
+	go r.replayEvents(events, speed)
+
+	return r, nil
+}
+
+// replayEvents sends events in order, an event of a request is delayed after the request is replayed,
+// as long as it was after the request in the recorded session
+func (r *replayer) replayEvents(events []*recordEntry, speed float64) {
+	defer r.wg.Done()
+	begin := time.Now()
+	for _, e := range events {
+		from, delay := begin, e.Time
+		if t, ok := r.recorded[e.ReqID]; ok {
+			if from, ok = r.waitRequest(e.ReqID); !ok {
+				return
+			}
+			delay = e.Time - t
+		}
+		at := from
+		if speed > 0 {
+			at = from.Add(time.Duration(float64(delay) / speed))
+		}
+
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-timer.C:
+		case <-r.done:
+			timer.Stop()
+			return
+		}
+
+		select {
+		case r.C <- event{State: e.State, RequestID: int64(e.ReqID), ErrCode: e.ErrCode}:
+		case <-r.done:
+			return
+		}
+	}
+	r.log.Infof("(wind) replay, all #%d events sent", len(events))
+}
+
+// waitRequest waits until reqid is replayed, and returns the time it's replayed
+func (r *replayer) waitRequest(reqid uint64) (time.Time, bool) {
+	for {
+		r.mu.Lock()
+		at, ok := r.requests[reqid]
+		notify := r.notify
+		r.mu.Unlock()
+		if ok {
+			return at, true
+		}
+		select {
+		case <-notify:
+		case <-r.done:
+			return time.Time{}, false
+		}
+	}
+}
+
+// take finds the first unused entry of op and method, preferring the one with the same args and reqid
+func (r *replayer) take(op, method string, args []interface{}, reqid uint64) (*recordEntry, error) {
+	key, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fallback := -1
+	for i, e := range r.entries {
+		if r.used[i] || e.Op != op || e.Method != method || e.ReqID != reqid && op != opRequest {
+			continue
+		}
+		if k, _ := json.Marshal(e.Args); string(k) == string(key) {
+			r.used[i] = true
+			return e, nil
+		}
+		if fallback < 0 {
+			fallback = i
+		}
+	}
+	if fallback < 0 {
+		return nil, fmt.Errorf("wind: no recorded %s %s(%d) to replay", op, method, reqid)
+	}
+	r.used[fallback] = true
+	return r.entries[fallback], nil
+}
+
+func (r *replayer) start(option1, option2 string, timeout int32) (int32, error) {
+	e, err := r.take(opStart, "", nil, 0)
+	if err != nil {
+		return 0, nil
+	}
+	return e.ErrCode, e.error()
+}
+
+func (r *replayer) stop() (int32, error) {
+	e, err := r.take(opStop, "", nil, 0)
+	if err != nil {
+		return 0, nil
+	}
+	return e.ErrCode, e.error()
+}
+
+func (r *replayer) connectionState() (int32, error) {
+	e, err := r.take(opState, "", nil, 0)
+	if err != nil {
+		return 0, nil
+	}
+	return e.State, e.error()
+}
+
+func (r *replayer) enableAsyn() error {
+	e, err := r.take(opAsyn, "", nil, 0)
+	if err != nil {
+		return nil
+	}
+	return e.error()
+}
+
+func (r *replayer) query(method string, args ...interface{}) (*rawData, error) {
+	e, err := r.take(opQuery, method, args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return e.raw()
+}
+
+func (r *replayer) request(method string, args ...interface{}) (uint64, int32, error) {
+	e, err := r.take(opRequest, method, args, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	r.mu.Lock()
+	r.requests[e.ReqID] = time.Now()
+	close(r.notify)
+	r.notify = make(chan struct{})
+	r.mu.Unlock()
+
+	return e.ReqID, e.ErrCode, e.error()
+}
+
+func (r *replayer) readdata(reqid uint64) (*rawData, error) {
+	e, err := r.take(opReaddata, "", nil, reqid)
+	if err != nil {
+		return nil, err
+	}
+	return e.raw()
+}
+
+func (r *replayer) cancel(reqid uint64) error {
+	if e, err := r.take(opCancel, "", nil, reqid); err == nil {
+		return e.error()
+	}
+	return nil
+}
+
+func (r *replayer) events() <-chan event {
+	return r.C
+}
+
+func (r *replayer) close() error {
+	select {
+	case <-r.done:
+		return nil
+	default:
+	}
+	close(r.done)
+	r.wg.Wait()
+	close(r.C)
+	return nil
+}
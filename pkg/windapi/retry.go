@@ -0,0 +1,108 @@
+package windapi
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how synchronous calls are retried on transient errors,
+// see WindError.Retryable, syntax and permission errors are never retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one, retry is disabled if <= 1
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each retry
+	Multiplier float64
+	// Jitter randomizes the delay by the given fraction, in [0, 1]
+	Jitter float64
+}
+
+// DefaultRetryPolicy is used by synchronous calls unless WithRetry is given
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// CallOption configures a single call
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	retry RetryPolicy
+	ctx   context.Context
+}
+
+// WithRetry sets the retry policy of a call, use WithRetry(RetryPolicy{}) to disable retrying
+func WithRetry(policy RetryPolicy) CallOption {
+	return func(o *callOptions) {
+		o.retry = policy
+	}
+}
+
+// WithContext makes a call cancelable, once ctx is done the in-flight request is canceled,
+// and the call returns ctx's error along with the request id, it works with any synchronous call
+func WithContext(ctx context.Context) CallOption {
+	return func(o *callOptions) {
+		o.ctx = ctx
+	}
+}
+
+// noRetry is used by calls that must be sent once only
+var noRetry = []CallOption{WithRetry(RetryPolicy{})}
+
+func newCallOptions(opts []CallOption) *callOptions {
+	o := &callOptions{
+		retry: DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// do calls fn until it succeeds, fails with an error which is not retryable,
+// runs out of attempts or ctx is done, in which case ctx's error is returned
+func (p RetryPolicy) do(ctx context.Context, fn func() error) error {
+	backoff := p.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		select {
+		case <-time.After(p.jitter(backoff)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff = p.grow(backoff)
+	}
+}
+
+// grow returns the delay following d
+func (p RetryPolicy) grow(d time.Duration) time.Duration {
+	if d = time.Duration(float64(d) * p.Multiplier); p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+func (p RetryPolicy) jitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+	return d + time.Duration(p.Jitter*float64(d)*(2*rand.Float64()-1))
+}
+
+func isRetryable(err error) bool {
+	var werr *WindError
+	return errors.As(err, &werr) && werr.Retryable()
+}
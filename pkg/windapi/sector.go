@@ -0,0 +1,53 @@
+package windapi
+
+import (
+	"time"
+)
+
+// WSES returns time series of sectors between begin and end, keyed by sector ID
+func WSES(sectorIDs, fields string, begin, end time.Time, options string, opts ...CallOption) (map[string][]*Series, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSES(sectorIDs, fields, begin, end, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSEE returns a snapshot of sectors, keyed by sector ID
+func WSEE(sectorIDs, fields, options string, opts ...CallOption) (map[string]*WindData, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSEE(sectorIDs, fields, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSES returns time series of sectors between begin and end,
+// sectorIDs is a comma separated list of wind's sector IDs, e.g. a001010100000000
+func (wind *windObj) WSES(sectorIDs, fields string, begin, end time.Time, options string, opts ...CallOption) (map[string][]*Series, error) {
+	data, err := wind.getWindData(opts, "wses_syn", sectorIDs, fields, begin.Format(dateLayout), end.Format(dateLayout), options)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]*Series)
+	for _, s := range toSeries(data) {
+		out[s.WindCode] = append(out[s.WindCode], s)
+	}
+	return out, nil
+}
+
+// WSEE returns a snapshot of sectors,
+// sectorIDs is a comma separated list of wind's sector IDs, e.g. a001010100000000
+func (wind *windObj) WSEE(sectorIDs, fields, options string, opts ...CallOption) (map[string]*WindData, error) {
+	data, err := wind.getWindData(opts, "wsee_syn", sectorIDs, fields, options)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*WindData, len(data))
+	for _, d := range data {
+		out[d.WindCode] = d
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package windapi
+
+import (
+	"errors"
+	"strings"
+)
+
+// errors of updating a subscription
+var (
+	ErrNoCodes            = errors.New("wind: no codes to subscribe")
+	ErrSubscriptionClosed = errors.New("wind: subscription is closed")
+)
+
+// EventKind is the kind of a SubscriptionEvent
+type EventKind int
+
+// kinds of subscription events
+const (
+	// EventState is reported when wind notifies the request with a state other than data ready,
+	// e.g. an error pushed by the server
+	EventState EventKind = iota
+	// EventReissued is reported when the request is reissued with a new ReqID,
+	// after reconnecting or updating codes and fields
+	EventReissued
+	// EventClosed is the last event, it's reported when the subscription is closed
+	EventClosed
+	// EventDisconnected is reported when the session of the request ends, e.g. after Logout or losing the connection,
+	// the subscription stays open, and is reissued once a new session starts
+	EventDisconnected
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventState:
+		return "state"
+	case EventReissued:
+		return "reissued"
+	case EventClosed:
+		return "closed"
+	case EventDisconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+// SubscriptionEvent reports what happens to a subscription besides its data
+type SubscriptionEvent struct {
+	Kind  EventKind
+	ReqID uint64
+	// State is the state code from wind, for EventState only
+	State int32
+	// Err is the error of wind's error code for EventState, why it's closed for EventClosed,
+	// or why the session ends for EventDisconnected, i.e. ErrLoggedOut or ErrConnectionFailed
+	Err error
+}
+
+// Err returns why the subscription is closed, e.g. ErrSubscriptionClosed if it's closed explicitly,
+// ErrClosing if the api is closed, or the error of its context, it returns nil while the subscription is open
+func (subs *Subscription) Err() error {
+	subs.wind.io.RLock()
+	defer subs.wind.io.RUnlock()
+	return subs.err
+}
+
+// Done returns a channel which is closed once the subscription is closed
+func (subs *Subscription) Done() <-chan struct{} {
+	return subs.done
+}
+
+// Events returns a channel reporting events of the subscription, it's closed after EventClosed.
+// Events are dropped if the channel is not drained in time.
+func (subs *Subscription) Events() <-chan SubscriptionEvent {
+	return subs.events
+}
+
+// report sends evt without blocking, it's called with wind.io locked
+func (subs *Subscription) report(evt SubscriptionEvent) {
+	select {
+	case subs.events <- evt:
+	default:
+	}
+}
+
+// AddCodes adds a comma separated list of codes to the subscription, codes already subscribed are skipped
+func (subs *Subscription) AddCodes(codes string) error {
+	return subs.update(func(cur []string) []string {
+		seen := make(map[string]bool, len(cur))
+		for _, code := range cur {
+			seen[strings.ToUpper(code)] = true
+		}
+		for _, code := range splitItems(codes) {
+			if key := strings.ToUpper(code); !seen[key] {
+				seen[key] = true
+				cur = append(cur, code)
+			}
+		}
+		return cur
+	}, "")
+}
+
+// RemoveCodes removes a comma separated list of codes from the subscription,
+// it fails with ErrNoCodes if no code would be left
+func (subs *Subscription) RemoveCodes(codes string) error {
+	return subs.update(func(cur []string) []string {
+		removed := make(map[string]bool)
+		for _, code := range splitItems(codes) {
+			removed[strings.ToUpper(code)] = true
+		}
+		var out []string
+		for _, code := range cur {
+			if !removed[strings.ToUpper(code)] {
+				out = append(out, code)
+			}
+		}
+		return out
+	}, "")
+}
+
+// SetFields replaces fields of the subscription
+func (subs *Subscription) SetFields(fields string) error {
+	return subs.update(nil, fields)
+}
+
+// update reissues the subscription with codes changed by fn and fields if they are not empty.
+// The new request is mapped onto the subscription before the former one is canceled,
+// so no update is lost in between, though some may be received twice.
+func (subs *Subscription) update(fn func(codes []string) []string, fields string) error {
+	wind := subs.wind
+
+	if err := wind.paceSubscribe(wind.ctx); err != nil {
+		return err
+	}
+
+	wind.io.Lock()
+	if subs.closed {
+		wind.io.Unlock()
+		return ErrSubscriptionClosed
+	}
+	codes := subs.codes
+	if fn != nil {
+		items := fn(splitItems(codes))
+		if len(items) == 0 {
+			wind.io.Unlock()
+			return ErrNoCodes
+		}
+		codes = strings.Join(items, ",")
+	}
+	if fields == "" {
+		fields = subs.fields
+	}
+
+	reqid, err := wind.wsq(codes, fields, subs.options)
+	if err != nil {
+		wind.io.Unlock()
+		return err
+	}
+	former := subs.reqid
+	subs.reqid, subs.session = reqid, wind.super.session
+	subs.codes, subs.fields = codes, fields
+	wind.io.ds[reqid] = subs
+	subs.report(SubscriptionEvent{Kind: EventReissued, ReqID: reqid})
+	wind.io.Unlock()
+
+	err = wind.cancel(former)
+	wind.io.Lock()
+	delete(wind.io.ds, former)
+	wind.io.Unlock()
+	return err
+}
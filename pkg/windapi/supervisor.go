@@ -0,0 +1,139 @@
+package windapi
+
+import (
+	"errors"
+	"time"
+)
+
+// supervise checks the connection every interval, and reconnects once it drops
+func (wind *windObj) supervise(interval time.Duration, backoff RetryPolicy) {
+	defer wind.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-wind.ctx.Done():
+			return
+		case <-ticker.C:
+			if wind.IsConnected() {
+				continue
+			}
+		case <-wind.super.kick:
+		}
+		// not supervised until Login
+		if wind.isLoggedOut() {
+			continue
+		}
+		wind.reconnect(backoff)
+	}
+}
+
+func (wind *windObj) isLoggedOut() bool {
+	wind.login.Lock()
+	defer wind.login.Unlock()
+	return wind.login.loggedOut
+}
+
+// reconnect logs in again and resubscribes, attempts are spaced by backoff until they succeed or wind is closed
+func (wind *windObj) reconnect(backoff RetryPolicy) {
+	if wind.ctx.Err() != nil {
+		return
+	}
+	wind.log.Warningf("(wind) connection lost, reconnecting")
+	// fail requests in flight and notify subscriptions without waiting for a new session
+	wind.endSession(ErrConnectionFailed)
+
+	var (
+		delay    = backoff.InitialBackoff
+		loggedIn bool
+		err      error
+	)
+	for attempt := 1; ; attempt++ {
+		if !loggedIn {
+			if err = wind.relogin(); err == ErrLoggedOut {
+				return
+			}
+			loggedIn = err == nil
+		}
+		if loggedIn {
+			if err = wind.resubscribe(); err == nil {
+				// drop kicks caused by the lost session
+				select {
+				case <-wind.super.kick:
+				default:
+				}
+				wind.log.Infof("(wind) reconnected after #%d attempts", attempt)
+				return
+			}
+			loggedIn = !isConnectionLost(err)
+		}
+		wind.log.Warningf("(wind) failed to reconnect, attempt #%d: %v", attempt, err)
+
+		select {
+		case <-time.After(backoff.jitter(delay)):
+		case <-wind.ctx.Done():
+			return
+		}
+		delay = backoff.grow(delay)
+	}
+}
+
+// resubscribe reissues subscriptions of former sessions, and maps their new request ids onto them,
+// a subscription failed with an error other than a lost connection is closed with the error
+func (wind *windObj) resubscribe() error {
+	var stale []*Subscription
+	wind.io.RLock()
+	for _, subs := range wind.io.ds {
+		if subs.session < wind.super.session {
+			stale = append(stale, subs)
+		}
+	}
+	wind.io.RUnlock()
+
+	for _, subs := range stale {
+		if err := wind.paceSubscribe(wind.ctx); err != nil {
+			return err
+		}
+
+		// it may be closed or updated meanwhile
+		wind.io.Lock()
+		if subs.closed || subs.session == wind.super.session {
+			wind.io.Unlock()
+			continue
+		}
+		reqid, err := wind.wsq(subs.codes, subs.fields, subs.options)
+		if err == nil {
+			delete(wind.io.ds, subs.reqid)
+			subs.reqid, subs.session = reqid, wind.super.session
+			wind.io.ds[reqid] = subs
+			subs.report(SubscriptionEvent{Kind: EventReissued, ReqID: reqid})
+		}
+		wind.io.Unlock()
+
+		switch {
+		case err == nil:
+		case isConnectionLost(err) || isRetryable(err):
+			return err
+		default:
+			wind.log.Errorf("(wind) failed to resubscribe %s: %v", subs.codes, err)
+			subs.close(err)
+		}
+	}
+	return nil
+}
+
+// watch wakes up the supervisor if err means the connection is lost, and returns err as is
+func (wind *windObj) watch(err error) error {
+	if isConnectionLost(err) {
+		select {
+		case wind.super.kick <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+func isConnectionLost(err error) bool {
+	return errors.Is(err, ErrConnectionFailed) || errors.Is(err, ErrLoggedInElsewhere)
+}
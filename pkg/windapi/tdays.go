@@ -0,0 +1,86 @@
+package windapi
+
+import (
+	"fmt"
+	"time"
+)
+
+// TDays returns trading days between begin and end,
+// options such as "TradingCalendar=SZSE;Period=W" select the exchange and period
+func TDays(begin, end time.Time, options string, opts ...CallOption) ([]time.Time, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.TDays(begin, end, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// TDaysOffset returns the trading day which is offset periods away from begin
+func TDaysOffset(begin time.Time, offset int, options string, opts ...CallOption) (time.Time, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.TDaysOffset(begin, offset, options, opts...)
+	}
+	return time.Time{}, ErrAPINotOpen
+}
+
+// TDaysCount returns the number of trading days between begin and end
+func TDaysCount(begin, end time.Time, options string, opts ...CallOption) (int, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.TDaysCount(begin, end, options, opts...)
+	}
+	return 0, ErrAPINotOpen
+}
+
+// TDays returns trading days between begin and end
+func (wind *windObj) TDays(begin, end time.Time, options string, opts ...CallOption) (days []time.Time, err error) {
+	err = wind.getRawData(opts, func(raw *rawData) error {
+		days = raw.times
+		return nil
+	}, "tdays_syn", begin.Format(dateLayout), end.Format(dateLayout), options)
+	return
+}
+
+// TDaysOffset returns the trading day which is offset periods away from begin
+func (wind *windObj) TDaysOffset(begin time.Time, offset int, options string, opts ...CallOption) (day time.Time, err error) {
+	err = wind.getRawData(opts, func(raw *rawData) error {
+		if len(raw.times) == 0 {
+			return fmt.Errorf("wind: no trading day at offset %d", offset)
+		}
+		day = raw.times[0]
+		return nil
+	}, "tdaysoffset_syn", begin.Format(dateLayout), int32(offset), options)
+	return
+}
+
+// TDaysCount returns the number of trading days between begin and end
+func (wind *windObj) TDaysCount(begin, end time.Time, options string, opts ...CallOption) (count int, err error) {
+	err = wind.getRawData(opts, func(raw *rawData) (err error) {
+		if len(raw.data) == 0 {
+			return fmt.Errorf("wind: empty trading days count")
+		}
+		count, err = toInt(raw.data[0])
+		return err
+	}, "tdayscount_syn", begin.Format(dateLayout), end.Format(dateLayout), options)
+	return
+}
+
+func toInt(val interface{}) (int, error) {
+	switch v := val.(type) {
+	case int:
+		return v, nil
+	case int32:
+		return int(v), nil
+	case int64:
+		return int(v), nil
+	case float32:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	}
+	return 0, fmt.Errorf("wind: invalid integer %v", val)
+}
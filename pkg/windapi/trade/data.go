@@ -0,0 +1,80 @@
+package trade
+
+import "time"
+
+// AccountType is the type of a trading account
+type AccountType string
+
+// well known account types
+const (
+	AccountSZ  AccountType = "SZ"  // 深圳
+	AccountSH  AccountType = "SH"  // 上海
+	AccountSZB AccountType = "SZB" // 深圳B
+	AccountSHB AccountType = "SHB" // 上海B
+	AccountCZC AccountType = "CZC" // 郑商所
+	AccountSHF AccountType = "SHF" // 上期所
+	AccountDCE AccountType = "DCE" // 大商所
+	AccountCFE AccountType = "CFE" // 中金所
+)
+
+// Side is the trade side of an order
+type Side string
+
+// well known trade sides
+const (
+	Buy   Side = "Buy"
+	Sell  Side = "Sell"
+	Short Side = "Short"
+	Cover Side = "Cover"
+)
+
+// Order is an order to be placed
+type Order struct {
+	LogonID      int
+	SecurityCode string
+	Side         Side
+	Price        float64
+	Volume       float64
+
+	// Options are passed to wind as is, e.g. "OrderType=LMT;HedgeType=SPEC"
+	Options string
+}
+
+// OrderStatus is the state of a placed order
+type OrderStatus struct {
+	RequestID    int
+	OrderNumber  string
+	SecurityCode string
+	Side         Side
+	Price        float64
+	Volume       float64
+	Status       string
+	TradedVolume float64
+	TradedPrice  float64
+	CancelVolume float64
+	OrderTime    time.Time
+}
+
+// Position is a holding of a logged on account
+type Position struct {
+	SecurityCode string
+	SecurityName string
+	Side         Side
+	Volume       float64
+	Available    float64
+	CostPrice    float64
+	LastPrice    float64
+	Profit       float64
+}
+
+// Account is the capital of a logged on account
+type Account struct {
+	LogonID       int
+	Currency      string
+	AvailableFund float64
+	BalanceFund   float64
+	FrozenFund    float64
+	SecurityValue float64
+	TotalAsset    float64
+	Profit        float64
+}
@@ -0,0 +1,22 @@
+package trade
+
+import (
+	"errors"
+	"fmt"
+)
+
+// well known errors
+var (
+	ErrEmptyResult = errors.New("trade: empty result")
+	ErrNotLoggedOn = errors.New("trade: not logged on")
+)
+
+// Error is an error reported by wind's trading api
+type Error struct {
+	Code int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("trade: %s(%d)", e.Msg, e.Code)
+}
@@ -0,0 +1,237 @@
+// Package trade wraps wind's trading api, orders are routed through the logged on wind terminal.
+package trade
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"restis.dev/go-wind/pkg/windapi"
+)
+
+// Querier calls a synchronous method of wind and returns its result as a table
+type Querier interface {
+	Query(method string, args ...interface{}) (*windapi.Table, error)
+}
+
+var _ Querier = (*windapi.Client)(nil)
+
+type defaultQuerier struct{}
+
+func (defaultQuerier) Query(method string, args ...interface{}) (*windapi.Table, error) {
+	return windapi.Query(method, args...)
+}
+
+// Trader logs on accounts, places and queries orders through wind
+type Trader struct {
+	api Querier
+}
+
+// New creates a trader using api, usually a *windapi.Client,
+// the package level api of windapi is used if api is nil
+func New(api Querier) *Trader {
+	if api == nil {
+		api = defaultQuerier{}
+	}
+	return &Trader{api: api}
+}
+
+// Logon logs on an account, and returns its logon ID
+func (t *Trader) Logon(brokerID, departmentID, account, password string, typ AccountType, options string) (int, error) {
+	table, err := t.query("tlogon", brokerID, departmentID, account, password, string(typ), options)
+	if err != nil {
+		return 0, err
+	}
+	logonID := toInt(row(table, 0)("LogonID"))
+	if logonID == 0 {
+		return 0, ErrNotLoggedOn
+	}
+	return logonID, nil
+}
+
+// Logout logs out the account of logonID
+func (t *Trader) Logout(logonID int) error {
+	_, err := t.query("tlogout", strconv.Itoa(logonID))
+	if err == ErrEmptyResult {
+		return nil
+	}
+	return err
+}
+
+// Order places an order, and returns its initial status
+func (t *Trader) Order(o *Order) (*OrderStatus, error) {
+	if o.LogonID == 0 {
+		return nil, ErrNotLoggedOn
+	}
+	table, err := t.query("torder", o.SecurityCode, string(o.Side), o.Price, o.Volume, withLogonID(o.Options, o.LogonID))
+	if err != nil {
+		return nil, err
+	}
+	return toOrderStatus(row(table, 0)), nil
+}
+
+// Cancel cancels the order of orderNumber
+func (t *Trader) Cancel(logonID int, orderNumber string) error {
+	if logonID == 0 {
+		return ErrNotLoggedOn
+	}
+	_, err := t.query("tcancel", orderNumber, withLogonID("", logonID))
+	if err == ErrEmptyResult {
+		return nil
+	}
+	return err
+}
+
+// Orders returns status of orders placed today
+func (t *Trader) Orders(logonID int, options string) ([]*OrderStatus, error) {
+	table, err := t.tquery("Order", logonID, options)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*OrderStatus, len(table.Rows))
+	for i := range table.Rows {
+		out[i] = toOrderStatus(row(table, i))
+	}
+	return out, nil
+}
+
+// Positions returns positions of the account
+func (t *Trader) Positions(logonID int, options string) ([]*Position, error) {
+	table, err := t.tquery("Position", logonID, options)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Position, len(table.Rows))
+	for i := range table.Rows {
+		r := row(table, i)
+		out[i] = &Position{
+			SecurityCode: toString(r("SecurityCode")),
+			SecurityName: toString(r("SecurityName")),
+			Side:         Side(toString(r("TradeSide"))),
+			Volume:       toFloat(r("SecurityVolume")),
+			Available:    toFloat(r("SecurityAvail")),
+			CostPrice:    toFloat(r("CostPrice")),
+			LastPrice:    toFloat(r("LastPrice")),
+			Profit:       toFloat(r("Profit")),
+		}
+	}
+	return out, nil
+}
+
+// Account returns capital of the account
+func (t *Trader) Account(logonID int) (*Account, error) {
+	table, err := t.tquery("Capital", logonID, "")
+	if err != nil {
+		return nil, err
+	}
+	r := row(table, 0)
+	return &Account{
+		LogonID:       logonID,
+		Currency:      toString(r("Currency")),
+		AvailableFund: toFloat(r("AvailableFund")),
+		BalanceFund:   toFloat(r("BalanceFund")),
+		FrozenFund:    toFloat(r("FrozenFund")),
+		SecurityValue: toFloat(r("SecurityValue")),
+		TotalAsset:    toFloat(r("TotalAsset")),
+		Profit:        toFloat(r("Profit")),
+	}, nil
+}
+
+func (t *Trader) tquery(code string, logonID int, options string) (*windapi.Table, error) {
+	if logonID == 0 {
+		return nil, ErrNotLoggedOn
+	}
+	table, err := t.query("tquery", code, withLogonID(options, logonID))
+	if err == ErrEmptyResult {
+		return &windapi.Table{}, nil
+	}
+	return table, err
+}
+
+// query calls method, and checks error codes reported in the result
+func (t *Trader) query(method string, args ...interface{}) (*windapi.Table, error) {
+	table, err := t.api.Query(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(table.Rows) == 0 {
+		return nil, ErrEmptyResult
+	}
+	if idx := table.Index("ErrorCode"); idx >= 0 {
+		for i := range table.Rows {
+			if code := toInt(table.Rows[i][idx]); code != 0 {
+				return nil, &Error{Code: code, Msg: toString(row(table, i)("ErrorMsg"))}
+			}
+		}
+	}
+	return table, nil
+}
+
+func withLogonID(options string, logonID int) string {
+	if options != "" {
+		options += ";"
+	}
+	return options + "LogonID=" + strconv.Itoa(logonID)
+}
+
+// row returns a accessor of the i-th row by column name
+func row(table *windapi.Table, i int) func(name string) interface{} {
+	return func(name string) interface{} {
+		if idx := table.Index(name); idx >= 0 {
+			return table.Rows[i][idx]
+		}
+		return nil
+	}
+}
+
+func toOrderStatus(r func(string) interface{}) *OrderStatus {
+	status := &OrderStatus{
+		RequestID:    toInt(r("RequestID")),
+		OrderNumber:  toString(r("OrderNumber")),
+		SecurityCode: toString(r("SecurityCode")),
+		Side:         Side(toString(r("TradeSide"))),
+		Price:        toFloat(r("OrderPrice")),
+		Volume:       toFloat(r("OrderVolume")),
+		Status:       toString(r("OrderStatus")),
+		TradedVolume: toFloat(r("TradedVolume")),
+		TradedPrice:  toFloat(r("TradedPrice")),
+		CancelVolume: toFloat(r("CancelVolume")),
+	}
+	if tm, ok := r("OrderTime").(time.Time); ok {
+		status.OrderTime = tm
+	}
+	return status
+}
+
+func toString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	return fmt.Sprint(val)
+}
+
+func toFloat(val interface{}) float64 {
+	switch v := val.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+func toInt(val interface{}) int {
+	return int(toFloat(val))
+}
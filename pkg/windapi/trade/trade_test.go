@@ -0,0 +1,48 @@
+package trade
+
+import (
+	"testing"
+
+	"restis.dev/go-wind/pkg/windapi"
+)
+
+type fakeQuerier map[string]*windapi.Table
+
+func (q fakeQuerier) Query(method string, args ...interface{}) (*windapi.Table, error) {
+	return q[method], nil
+}
+
+func TestOrder(t *testing.T) {
+	trader := New(fakeQuerier{
+		"torder": {
+			Columns: []string{"RequestID", "SecurityCode", "TradeSide", "OrderPrice", "OrderVolume", "LogonID", "ErrorCode", "ErrorMsg"},
+			Rows:    [][]interface{}{{12.0, "600588.SH", "Buy", 10.5, 100.0, 1.0, 0.0, ""}},
+		},
+	})
+
+	if _, err := trader.Order(&Order{SecurityCode: "600588.SH", Side: Buy, Price: 10.5, Volume: 100}); err != ErrNotLoggedOn {
+		t.Fatalf("expected ErrNotLoggedOn, got %v", err)
+	}
+
+	status, err := trader.Order(&Order{LogonID: 1, SecurityCode: "600588.SH", Side: Buy, Price: 10.5, Volume: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RequestID != 12 || status.Side != Buy || status.Volume != 100 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestError(t *testing.T) {
+	trader := New(fakeQuerier{
+		"tquery": {
+			Columns: []string{"ErrorCode", "ErrorMsg"},
+			Rows:    [][]interface{}{{-1.0, "no logon"}},
+		},
+	})
+
+	_, err := trader.Positions(1, "")
+	if e, ok := err.(*Error); !ok || e.Code != -1 || e.Msg != "no logon" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
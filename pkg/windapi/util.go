@@ -1,6 +1,24 @@
 package windapi
 
-import ole "restis.dev/go-ole"
+import (
+	"errors"
+	"strings"
+	"time"
+
+	ole "restis.dev/go-ole"
+)
+
+// layouts of date and time arguments accepted by wind
+const (
+	dateLayout     = "2006-01-02"
+	datetimeLayout = "2006-01-02 15:04:05"
+)
+
+// wsiMaxPoints is the number of data points(bars x codes x fields) requested in one wsi call
+const wsiMaxPoints = 100000
+
+// wstWindow is the time span requested in one wst call
+const wstWindow = 30 * time.Minute
 
 func createObject(programID string) (unknown *ole.IUnknown, err error) {
 	classID, err := ole.ClassIDFrom(programID)
@@ -19,3 +37,68 @@ func createObject(programID string) (unknown *ole.IUnknown, err error) {
 func callMethod(disp *ole.IDispatch, name string, params ...interface{}) (result *ole.VARIANT, err error) {
 	return disp.InvokeWithOptionalArgs(name, ole.DISPATCH_METHOD, params)
 }
+
+// optionValue looks up key in wind's "Key1=Value1;Key2=Value2" options, key is case insensitive
+func optionValue(options, key string) (string, bool) {
+	for _, kv := range strings.Split(options, ";") {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(kv[:i]), key) {
+			return strings.TrimSpace(kv[i+1:]), true
+		}
+	}
+	return "", false
+}
+
+// countItems returns the number of items in a comma separated list
+func countItems(list string) int {
+	return strings.Count(list, ",") + 1
+}
+
+// splitItems returns items of a comma separated list, blanks are skipped
+func splitItems(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// fetchPaged splits [begin, end] into windows of span, and calls fn for each window in order,
+// entries at the end of a window are left to the next one, so no entry is returned twice.
+// A window is halved and retried when fn fails with ErrDataLimit, until it reaches minSpan.
+func fetchPaged(begin, end time.Time, span, minSpan time.Duration, fn func(b, e time.Time) ([]*WindData, error)) ([]*WindData, error) {
+	var out []*WindData
+	for b := begin; ; {
+		e := b.Add(span)
+		if !e.Before(end) {
+			e = end
+		}
+
+		data, err := fn(b, e)
+		if errors.Is(err, ErrDataLimit) && span > minSpan {
+			if span /= 2; span < minSpan {
+				span = minSpan
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		last := !e.Before(end)
+		for _, d := range data {
+			if last || d.UpdateTime.Before(e) {
+				out = append(out, d)
+			}
+		}
+		if last {
+			return out, nil
+		}
+		b = e
+	}
+}
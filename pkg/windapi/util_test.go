@@ -0,0 +1,260 @@
+package windapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestJoinHoldings(t *testing.T) {
+	day := time.Date(2019, 10, 8, 0, 0, 0, 0, time.Local)
+	dates, codes, quantities, prices := joinHoldings([]PortfolioHolding{
+		{TradeDate: day, WindCode: "600588.SH", Quantity: 100, CostPrice: 10.5},
+		{TradeDate: day, WindCode: "000001.SZ", Quantity: 200, CostPrice: 12},
+	})
+	if dates != "20191008,20191008" || codes != "600588.SH,000001.SZ" || quantities != "100,200" || prices != "10.5,12" {
+		t.Errorf("unexpected holdings: %s|%s|%s|%s", dates, codes, quantities, prices)
+	}
+}
+
+func TestCodeIndex(t *testing.T) {
+	var calls int
+	idx, err := newCodeIndex(func(report, options string, opts ...CallOption) (*Table, error) {
+		return &Table{
+			Columns: []string{"date", "wind_code", "sec_name"},
+			Rows: [][]interface{}{
+				{nil, "600588.SH", "用友网络"},
+				{nil, "600589.SH", "广东榕泰"},
+				{nil, "000001.SZ", "平安银行"},
+			},
+		}, nil
+	}, func(names, secType, options string, opts ...CallOption) ([]string, error) {
+		calls++
+		return []string{"600588.SH"}, nil
+	}, []string{SectorAllA})
+	panicOnErr(err)
+
+	if res := idx.Lookup("60058", 10); len(res) != 2 || res[0].WindCode != "600588.SH" {
+		t.Errorf("unexpected prefix lookup: %v", res)
+	}
+	if res := idx.Lookup("600589", 10); len(res) != 1 || res[0].Name != "广东榕泰" {
+		t.Errorf("unexpected ticker lookup: %v", res)
+	}
+	if res := idx.Lookup("银行", 10); len(res) != 1 || res[0].WindCode != "000001.SZ" {
+		t.Errorf("unexpected name lookup: %v", res)
+	}
+
+	code, err := idx.Resolve("平安银行", "stocks")
+	panicOnErr(err)
+	if code != "000001.SZ" || calls != 0 {
+		t.Errorf("expected local resolution, got %s with %d calls", code, calls)
+	}
+	for i := 0; i < 2; i++ {
+		code, err = idx.Resolve("yywl", "stocks")
+		panicOnErr(err)
+	}
+	if code != "600588.SH" || calls != 1 {
+		t.Errorf("expected one htocode call, got %s with %d calls", code, calls)
+	}
+	if res := idx.Lookup("YYWL", 1); len(res) != 1 || res[0].WindCode != "600588.SH" {
+		t.Errorf("unexpected alias lookup: %v", res)
+	}
+
+	idx.Add(&Table{
+		Columns: []string{"wind_code", "sec_name"},
+		Rows:    [][]interface{}{{"601318.SH", "中国平安"}},
+	})
+	if code, err = idx.Resolve("中国平安", "stocks"); code != "601318.SH" || calls != 1 {
+		t.Errorf("expected local resolution of an added name, got %s with %d calls, %v", code, calls, err)
+	}
+}
+
+func TestParseErr(t *testing.T) {
+	err := parseErr(-40521010)
+	if !errors.Is(err, ErrNetworkTimeout) || errors.Is(err, ErrUnsupportedField) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var werr *WindError
+	if !errors.As(fmt.Errorf("wss: %w", err), &werr) {
+		t.Fatalf("expected a wrapped WindError")
+	}
+	if werr.Category != CategoryNetwork || !werr.Retryable() {
+		t.Errorf("expected a retryable network error, got %v(%v)", werr, werr.Category)
+	}
+
+	if werr, ok := parseErr(-40522007).(*WindError); !ok || werr.Category != CategoryRequest || werr.Retryable() {
+		t.Errorf("expected an unretryable request error, got %v", werr)
+	}
+	if werr, ok := parseErr(-40522999).(*WindError); !ok || werr.Category != CategoryRequest || werr.Error() != "wind: unknown error(-40522999)" {
+		t.Errorf("unexpected unknown error: %v", werr)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 2, Jitter: 0.5}
+
+	var calls int
+	err := policy.do(context.Background(), func() error {
+		calls++
+		return ErrNetworkTimeout
+	})
+	if !errors.Is(err, ErrNetworkTimeout) || calls != 3 {
+		t.Errorf("expected 3 attempts, got %d with %v", calls, err)
+	}
+
+	calls = 0
+	err = policy.do(context.Background(), func() error {
+		calls++
+		return ErrUnsupportedField
+	})
+	if !errors.Is(err, ErrUnsupportedField) || calls != 1 {
+		t.Errorf("expected no retry, got %d attempts with %v", calls, err)
+	}
+
+	calls = 0
+	err = policy.do(context.Background(), func() error {
+		if calls++; calls == 1 {
+			return ErrTimeout
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected success on retry, got %d attempts with %v", calls, err)
+	}
+
+	calls = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}.do(ctx, func() error {
+		calls++
+		return ErrNetworkTimeout
+	})
+	if err != context.DeadlineExceeded || calls != 1 {
+		t.Errorf("expected deadline exceeded while backing off, got %d attempts with %v", calls, err)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(RateLimit{Rate: 100, Burst: 2})
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		panicOnErr(l.wait(context.Background()))
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected calls to be paced, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited before deadline, got %v", err)
+	}
+
+	l = newLimiter(RateLimit{Rate: 1, Burst: 1, FailFast: true})
+	panicOnErr(l.wait(context.Background()))
+	if err := l.wait(context.Background()); err != ErrRateLimited {
+		t.Errorf("expected fail fast, got %v", err)
+	}
+	l = newLimiter(RateLimit{Rate: 100, Burst: 1, FailFast: true})
+	panicOnErr(l.wait(context.Background()))
+	if err := l.waitAlways(context.Background()); err != nil {
+		t.Errorf("expected waiting for a token, got %v", err)
+	}
+
+	if err := newLimiter(RateLimit{}).wait(context.Background()); err != nil {
+		t.Errorf("expected no limit, got %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := newLimiter(RateLimit{}).wait(ctx); err != context.Canceled {
+		t.Errorf("expected context canceled without limit, got %v", err)
+	}
+}
+
+func TestFetchPaged(t *testing.T) {
+	begin := time.Date(2019, 10, 8, 9, 30, 0, 0, time.Local)
+	end := begin.Add(time.Hour)
+
+	var calls int
+	data, err := fetchPaged(begin, end, 40*time.Minute, time.Minute, func(b, e time.Time) ([]*WindData, error) {
+		calls++
+		if e.Sub(b) > 20*time.Minute {
+			return nil, ErrDataLimit
+		}
+		var out []*WindData
+		for tm := b; !tm.After(e); tm = tm.Add(time.Minute) {
+			out = append(out, &WindData{UpdateTime: tm})
+		}
+		return out, nil
+	})
+	panicOnErr(err)
+
+	if len(data) != 61 {
+		t.Fatalf("expected 61 bars, got %d", len(data))
+	}
+	for i, d := range data {
+		if !d.UpdateTime.Equal(begin.Add(time.Duration(i) * time.Minute)) {
+			t.Fatalf("unexpected bar #%d at %v", i, d.UpdateTime)
+		}
+	}
+	if calls != 4 {
+		t.Errorf("expected 4 calls, got %d", calls)
+	}
+}
+
+func TestToSeries(t *testing.T) {
+	t0 := time.Date(2019, 10, 8, 0, 0, 0, 0, time.Local)
+	t1 := t0.AddDate(0, 0, 1)
+	fields := []string{"close", "volume"}
+	data := []*WindData{
+		{UpdateTime: t0, WindCode: "A", Fields: fields, Values: []interface{}{1.0, 10.0}},
+		{UpdateTime: t0, WindCode: "B", Fields: fields, Values: []interface{}{2.0, 20.0}},
+		{UpdateTime: t1, WindCode: "A", Fields: fields, Values: []interface{}{3.0, 30.0}},
+		{UpdateTime: t1, WindCode: "B", Fields: fields, Values: []interface{}{4.0, 40.0}},
+	}
+
+	series := toSeries(data)
+	if len(series) != 4 {
+		t.Fatalf("expected 4 series, got %d", len(series))
+	}
+	s := series[3]
+	if s.WindCode != "B" || s.Field != "volume" {
+		t.Fatalf("unexpected series order: %v", s)
+	}
+	if len(s.Times) != 2 || !s.Times[1].Equal(t1) || s.Values[1] != 40.0 {
+		t.Errorf("unexpected series: %v", s)
+	}
+}
+
+func panicOnErr(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func TestPumpEvents(t *testing.T) {
+	in, out := make(chan event), make(chan event)
+	go pumpEvents(in, out)
+
+	// sending never blocks on the consumer
+	for i := 0; i < 100; i++ {
+		select {
+		case in <- event{State: 1, RequestID: int64(i)}:
+		case <-time.After(time.Second):
+			t.Fatalf("blocked when sending event #%d", i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if evt := <-out; evt.RequestID != int64(i) {
+			t.Fatalf("expected event #%d, got %v", i, evt)
+		}
+	}
+
+	close(in)
+	if _, ok := <-out; ok {
+		t.Error("expected out to be closed")
+	}
+}
@@ -4,22 +4,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"k8s.io/klog"
-	ole "restis.dev/go-ole"
 	"restis.dev/go-wind/pkg/errs"
 )
 
 // Subscription is returned from wind's WSQ
 type Subscription struct {
-	c chan []*WindData
-
-	err   error
-	wind  *windObj
-	reqid uint64
+	// dropped is accessed atomically, keep it 64-bit aligned
+	dropped uint64
+
+	c      chan []*WindData
+	done   chan struct{}
+	events chan SubscriptionEvent
+
+	policy Backpressure
+	queue  queue
+	// sending is held by ioloop while pushing to c, so that c isn't closed meanwhile
+	sending sync.Mutex
+
+	// closed, err, reqid and session are guarded by wind.io,
+	// reqid changes when the subscription is reissued after reconnecting
+	closed  bool
+	err     error
+	wind    *windObj
+	reqid   uint64
+	session uint64
+
+	codes, fields, options string
 }
 
 // C returns channel for receiving data
@@ -27,20 +43,40 @@ func (subs *Subscription) C() <-chan []*WindData {
 	return subs.c
 }
 
-// Close unsubscribes and cleans up
+// Close unsubscribes and cleans up, it returns the reason if the subscription has been closed,
+// e.g. ErrClosing or the error of its context
 func (subs *Subscription) Close() error {
-	if subs.err != nil {
+	return subs.close(nil)
+}
+
+// close cancels the request and closes the subscription with reason,
+// it's closed explicitly if reason is nil, in which case the error of cancel is returned
+func (subs *Subscription) close(reason error) error {
+	wind := subs.wind
+	wind.io.Lock()
+	if subs.closed {
+		wind.io.Unlock()
 		return subs.err
 	}
+	subs.closed = true
+	reqid := subs.reqid
+	wind.io.Unlock()
+
 	// cancel first
-	subs.err = subs.wind.cancel(subs.reqid)
-	subs.wind.io.Lock()
-	defer subs.wind.io.Unlock()
-	subs.wind.io.ds[subs.reqid] = nil
-	delete(subs.wind.io.ds, subs.reqid)
+	err := wind.cancel(reqid)
+
+	wind.io.Lock()
+	defer wind.io.Unlock()
+	delete(wind.io.ds, reqid)
+	if subs.err = reason; reason == nil {
+		subs.err = ErrSubscriptionClosed
+	}
 	// close receiving channel
-	close(subs.c)
-	return subs.err
+	subs.shutdown()
+	if reason == nil {
+		return err
+	}
+	return reason
 }
 
 // well known constants
@@ -53,22 +89,23 @@ const (
 var (
 	ErrAPINotOpen = errors.New("wind: api does not open")
 	ErrClosing    = errors.New("wind: io is closing")
+	ErrLoggedOut  = errors.New("wind: logged out")
 )
 
 var (
-	apiInst *windObj
+	apiInst *Client
 	apiLock sync.RWMutex
 )
 
 // Open opens and starts wind's api,
 // it internally creates a COM object and starts a message queue,
 // if the object has been initialized, it reset the logger.
-func Open() (err error) {
+func Open(opts ...Option) (err error) {
 	apiLock.Lock()
 	defer apiLock.Unlock()
 
 	if apiInst == nil {
-		apiInst, err = newAPI()
+		apiInst, err = New(opts...)
 		return
 	}
 	return
@@ -79,7 +116,7 @@ func Close() (err error) {
 	apiLock.Lock()
 	defer apiLock.Unlock()
 	if apiInst != nil {
-		err = apiInst.close()
+		err = apiInst.Close()
 		apiInst = nil
 		return
 	}
@@ -87,25 +124,137 @@ func Close() (err error) {
 }
 
 // WSQ subscribes realtime data using wind's api
-func WSQ(codes, fields, options string) (*Subscription, error) {
+func WSQ(codes, fields, options string, opts ...SubscribeOption) (*Subscription, error) {
 	apiLock.RLock()
 	defer apiLock.RUnlock()
 	if apiInst != nil {
-		return apiInst.WSQ(codes, fields, options)
+		return apiInst.WSQ(codes, fields, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSQContext subscribes realtime data like WSQ, the subscription is closed once ctx is done
+func WSQContext(ctx context.Context, codes, fields, options string, opts ...SubscribeOption) (*Subscription, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSQContext(ctx, codes, fields, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSQSnapshot returns current quotes once, without subscribing
+func WSQSnapshot(codes, fields string, opts ...CallOption) ([]*WindData, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSQSnapshot(codes, fields, opts...)
 	}
 	return nil, ErrAPINotOpen
 }
 
 // WSS returns multidimensional data from wind
-func WSS(codes, fields, options string) ([]*WindData, error) {
+func WSS(codes, fields, options string, opts ...CallOption) ([]*WindData, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSS(codes, fields, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSSContext returns multidimensional data like WSS, the request is canceled once ctx is done
+func WSSContext(ctx context.Context, codes, fields, options string, opts ...CallOption) ([]*WindData, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSSContext(ctx, codes, fields, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSI returns intraday minute bars of the given codes between begin and end,
+// long ranges are fetched in several calls to stay under wind's extraction limit
+func WSI(codes, fields string, begin, end time.Time, options string, opts ...CallOption) ([]*WindData, error) {
 	apiLock.RLock()
 	defer apiLock.RUnlock()
 	if apiInst != nil {
-		return apiInst.WSS(codes, fields, options)
+		return apiInst.WSI(codes, fields, begin, end, options, opts...)
 	}
 	return nil, ErrAPINotOpen
 }
 
+// WST returns intraday ticks of the given codes between begin and end in time order
+func WST(codes, fields string, begin, end time.Time, options string, opts ...CallOption) ([]*WindData, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WST(codes, fields, begin, end, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSD returns time series of the given codes and fields between begin and end
+func WSD(codes, fields string, begin, end time.Time, options string, opts ...CallOption) ([]*Series, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSD(codes, fields, begin, end, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// EDB returns series of macro-economic indicators between begin and end, one series per indicator
+func EDB(codes string, begin, end time.Time, options string, opts ...CallOption) ([]*Series, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.EDB(codes, begin, end, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// WSET returns a report from wind as a table, e.g. sector constituents or index weights
+func WSET(report, options string, opts ...CallOption) (*Table, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.WSET(report, options, opts...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// Query calls a synchronous method of wind's COM object with args, and returns its result as a table,
+// it is the building block of packages like trade, the call is never retried
+func Query(method string, args ...interface{}) (*Table, error) {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.Query(method, args...)
+	}
+	return nil, ErrAPINotOpen
+}
+
+// Login switches the account at runtime, see Client.Login
+func Login(username, password string) error {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.Login(username, password)
+	}
+	return ErrAPINotOpen
+}
+
+// Logout ends the session, see Client.Logout
+func Logout() error {
+	apiLock.RLock()
+	defer apiLock.RUnlock()
+	if apiInst != nil {
+		return apiInst.Logout()
+	}
+	return ErrAPINotOpen
+}
+
 // IsConnected checks api connection status
 func IsConnected() bool {
 	apiLock.RLock()
@@ -116,22 +265,15 @@ func IsConnected() bool {
 	return false
 }
 
-// windObj is wrapper of wind's COM
+// windObj is wrapper of wind's api
 type windObj struct {
-	wind *ole.IDispatch
-
-	msgloop struct {
-		running bool // flag if main loop is running
-	}
-
-	evtsink *eventReceiver
+	backend backend
 
 	C <-chan event
 
 	ctx struct {
 		context.Context
 		cancel context.CancelFunc
-		tid    uint32
 	}
 
 	wg sync.WaitGroup
@@ -140,137 +282,222 @@ type windObj struct {
 		sync.Once
 		sync.RWMutex
 		ds map[uint64]*Subscription
+		// futures are requests waiting for their results, see request
+		futures map[uint64]*Future
+	}
+
+	asyn struct {
+		sync.Mutex
+		enabled bool
+	}
+
+	// login is what the api logs in with, it's used again when reconnecting
+	login struct {
+		sync.Mutex
+		o         *options
+		loggedOut bool
+	}
+
+	super struct {
+		// kick wakes up the supervisor when the connection is known to be lost
+		kick chan struct{}
+		// session counts logins, guarded by io
+		session uint64
+	}
+
+	limits struct {
+		query     *limiter
+		subscribe *limiter
 	}
 
+	log Logger
+
 	err error
 }
 
 // newAPI creates a new windapi object
-func newAPI() (*windObj, error) {
-	w := &windObj{}
+func newAPI(opts ...Option) (*windObj, error) {
+	o := newOptions(opts)
+
+	w := &windObj{log: o.logger}
+	w.limits.query = newLimiter(o.queryLimit)
+	w.limits.subscribe = newLimiter(o.subscribeLimit)
 	w.ctx.Context, w.ctx.cancel = context.WithCancel(context.Background())
 
-	// All initialisation should occur in the same OS thread,
-	// for it's main message loop to reside in.
-	// Here we use a error channel to coordinate this process
-	waitErrC := make(chan error)
+	newBackend := o.newBackend
+	if newBackend == nil {
+		newBackend = func(o *options) (backend, error) {
+			return newCOMBackend(o.logger)
+		}
+	}
+	b, err := newBackend(o)
+	if err != nil {
+		return nil, err
+	}
+	if o.record != nil {
+		b = newRecorder(b, o.record)
+	}
+	w.backend = b
+	w.C = w.backend.events()
 
-	w.wg.Add(1)
-	go func() {
-		defer w.wg.Done()
+	w.login.o = o
+	if err := w.start(o.startOptions(), "", o.startTimeoutMillis()); err != nil {
+		w.ctx.cancel()
+		return nil, errs.And(err, w.backend.close())
+	}
 
-		// lock on current os thread
-		runtime.LockOSThread()
-		defer runtime.UnlockOSThread()
+	if o.reconnectInterval > 0 {
+		w.super.kick = make(chan struct{}, 1)
+		w.wg.Add(1)
+		go w.supervise(o.reconnectInterval, o.reconnectBackoff)
+	}
 
-		eventC := make(chan event, 1)
-		defer close(eventC)
+	return w, nil
+}
 
-		// init message loop
-		if err := func() error {
-			if err := ole.CoInitializeEx(0, 0x0); err != nil {
-				return err
-			}
+func (wind *windObj) IsConnected() bool {
+	state, err := wind.backend.connectionState()
+	return err == nil && state == 0
+}
 
-			unknown, err := createObject(ProgramID)
-			if err != nil {
-				return err
-			}
-			defer unknown.Release()
+// WSQ subscribes realtime data using wind's api,
+// by default the subscription blocks delivery when its consumer falls behind, see WithBackpressure
+func (wind *windObj) WSQ(codes, fields, options string, opts ...SubscribeOption) (*Subscription, error) {
+	return wind.WSQContext(context.Background(), codes, fields, options, opts...)
+}
 
-			w.wind, err = unknown.QueryInterface(ole.IID_IDispatch)
-			if err != nil {
-				return err
-			}
+// WSQContext subscribes realtime data using wind's api,
+// once ctx is done, the request is canceled and the subscription is closed with ctx's error
+func (wind *windObj) WSQContext(ctx context.Context, codes, fields, options string, opts ...SubscribeOption) (*Subscription, error) {
+	o := newSubscribeOptions(opts)
+	wind.startIO()
 
-			// connect event source to sink
-			r := newEventReceiver(eventC)
-			if err := adviseEventReceiver(w.wind, r); err != nil {
-				return errs.And(err, w.close())
-			}
-			w.evtsink = r
-			w.C = eventC
+	if err := wind.paceSubscribe(ctx); err != nil {
+		return nil, err
+	}
 
-			return w.start("", "", 5000)
+	wind.io.Lock()
+	reqid, err := wind.wsq(codes, fields, options)
+	if err != nil {
+		wind.io.Unlock()
+		return nil, err
+	}
 
-		}(); err != nil {
-			waitErrC <- err
-			return
-		}
+	subs := &Subscription{
+		c:       make(chan []*WindData, o.buffer),
+		done:    make(chan struct{}),
+		events:  make(chan SubscriptionEvent, 16),
+		policy:  o.policy,
+		reqid:   reqid,
+		session: wind.super.session,
+		wind:    wind,
+		codes:   codes,
+		fields:  fields,
+		options: options,
+	}
+	if subs.policy.pumped() {
+		subs.queue.wake = make(chan struct{}, 1)
+		subs.queue.latest = make(map[string]*WindData)
+		go subs.pump()
+	}
+	wind.io.ds[reqid] = subs
+	wind.io.Unlock()
 
-		// init finished,
-		// notify outside world, we r ready to work
-		close(waitErrC)
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				subs.close(fmt.Errorf("wind: request %d: %w", reqid, ctx.Err()))
+			case <-subs.done:
+			}
+		}()
+	}
 
-		w.ctx.tid = getCurrentThreadID()
+	return subs, nil
+}
 
-		w.msgloop.running = true
-		klog.Infof("(wind) message loop started at tid: %d", w.ctx.tid)
+// WSQSnapshot returns current quotes once, it keeps no subscription
+func (wind *windObj) WSQSnapshot(codes, fields string, opts ...CallOption) ([]*WindData, error) {
+	return wind.getWindData(opts, "wsq_syn", codes, fields, "")
+}
 
-		defer klog.Infof("(wind) message loop exited")
-		defer ole.CoUninitialize()
-		defer func() { w.msgloop.running = false }()
+// WSS returns multidimensional data from wind
+func (wind *windObj) WSS(codes, fields, options string, opts ...CallOption) ([]*WindData, error) {
+	return wind.getWindData(opts, "wss_syn", codes, fields, options)
+}
 
-		var m ole.Msg
-		for w.ctx.Err() == nil {
-			rc, _ := ole.GetMessage(&m, 0, 0, 0)
-			if rc == 0 {
-				klog.Info("(wind) message loop with return code: 0")
-				break
-			}
-			if rc != -1 {
-				ole.DispatchMessage(&m)
-			}
+// WSSContext returns multidimensional data from wind, the request is canceled once ctx is done
+func (wind *windObj) WSSContext(ctx context.Context, codes, fields, options string, opts ...CallOption) ([]*WindData, error) {
+	return wind.WSS(codes, fields, options, append(opts, WithContext(ctx))...)
+}
+
+// WSI returns intraday minute bars of the given codes between begin and end.
+// The range is split into windows sized by BarSize and the number of codes and fields,
+// a window is halved whenever wind reports that the extraction limit is exceeded.
+func (wind *windObj) WSI(codes, fields string, begin, end time.Time, options string, opts ...CallOption) ([]*WindData, error) {
+	barSize := time.Minute
+	if val, ok := optionValue(options, "BarSize"); ok {
+		n, err := strconv.Atoi(val)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("wind: invalid BarSize %q", val)
 		}
-	}()
+		barSize = time.Duration(n) * time.Minute
+	}
 
-	if err := <-waitErrC; err != nil {
-		return nil, err
+	bars := wsiMaxPoints / (countItems(codes) * countItems(fields))
+	if bars < 1 {
+		bars = 1
 	}
 
-	return w, nil
+	return fetchPaged(begin, end, time.Duration(bars)*barSize, barSize, func(b, e time.Time) ([]*WindData, error) {
+		return wind.getWindData(opts, "wsi_syn", codes, fields, b.Format(datetimeLayout), e.Format(datetimeLayout), options)
+	})
 }
 
-func (wind *windObj) IsConnected() bool {
-	if !wind.msgloop.running {
-		return false
+// WST returns intraday ticks of the given codes between begin and end in time order,
+// the range is paged into windows of wstWindow, which are halved on extraction limit.
+func (wind *windObj) WST(codes, fields string, begin, end time.Time, options string, opts ...CallOption) ([]*WindData, error) {
+	data, err := fetchPaged(begin, end, wstWindow, time.Minute, func(b, e time.Time) ([]*WindData, error) {
+		return wind.getWindData(opts, "wst_syn", codes, fields, b.Format(datetimeLayout), e.Format(datetimeLayout), options)
+	})
+	if err != nil {
+		return nil, err
 	}
-	var state int32
-	_, err := callMethod(wind.wind, "getConnectionState", &state)
-	return err == nil && state == 0
-}
-
-// WSQ subscribes realtime data using wind's api
-func (wind *windObj) WSQ(codes, fields, options string) (*Subscription, error) {
-	// ensure starts ioloop lazily
-	wind.io.Do(func() {
-		wind.io.ds = make(map[uint64]*Subscription)
-		wind.wg.Add(1)
-		go wind.ioloop()
+	sort.SliceStable(data, func(i, j int) bool {
+		return data[i].UpdateTime.Before(data[j].UpdateTime)
 	})
+	return data, nil
+}
 
-	wind.io.Lock()
-	defer wind.io.Unlock()
-	reqid, err := wind.wsq(codes, fields, options)
+// WSD returns time series of the given codes and fields between begin and end,
+// one series per code and field
+func (wind *windObj) WSD(codes, fields string, begin, end time.Time, options string, opts ...CallOption) ([]*Series, error) {
+	data, err := wind.getWindData(opts, "wsd_syn", codes, fields, begin.Format(dateLayout), end.Format(dateLayout), options)
 	if err != nil {
 		return nil, err
 	}
+	return toSeries(data), nil
+}
 
-	subs := &Subscription{
-		c:     make(chan []*WindData, 1),
-		reqid: reqid,
-		wind:  wind,
+// EDB returns series of macro-economic indicators(e.g. M0001385) between begin and end,
+// one series per indicator
+func (wind *windObj) EDB(codes string, begin, end time.Time, options string, opts ...CallOption) ([]*Series, error) {
+	data, err := wind.getWindData(opts, "edb_syn", codes, begin.Format(dateLayout), end.Format(dateLayout), options)
+	if err != nil {
+		return nil, err
 	}
-	wind.io.ds[reqid] = subs
+	return toSeries(data), nil
+}
 
-	return subs, nil
+// WSET returns a report from wind as a table, columns are named after the report's fields
+func (wind *windObj) WSET(report, options string, opts ...CallOption) (*Table, error) {
+	return wind.getTable(opts, "wset_syn", report, options)
 }
 
-// WSS returns multidimensional data from wind
-func (wind *windObj) WSS(codes, fields, options string) ([]*WindData, error) {
-	return wind.getWindData(func(codesOut, fieldsOut, timesOut *ole.VARIANT, ec *int32) (*ole.VARIANT, error) {
-		return callMethod(wind.wind, "wss_syn", codes, fields, options, codesOut, fieldsOut, timesOut, ec)
-	})
+// Query calls a synchronous method of wind's COM object with args, and returns its result as a table,
+// the call is never retried, since methods like torder are not idempotent
+func (wind *windObj) Query(method string, args ...interface{}) (*Table, error) {
+	return wind.getTable(noRetry, method, args...)
 }
 
 // close closes the wind api object and cleans up
@@ -279,35 +506,27 @@ func (wind *windObj) close() error {
 		return wind.err
 	}
 
-	if wind.wind != nil {
-		if wind.evtsink != nil {
-			wind.cancel(0) // nolint
-			if err := unadviseEventReceiver(wind.wind, wind.evtsink); err != nil {
-				wind.err = errs.And(wind.err, err)
-			}
-		}
+	wind.cancel(0) // nolint
 
-		wind.ctx.cancel()
-		wind.evtsink = nil
-
-		wind.wind.Release()
-		wind.wind = nil
+	// keep the supervisor from logging in again
+	wind.login.Lock()
+	wind.login.loggedOut = true
+	wind.login.Unlock()
+	if err := wind.stop(); err != nil {
+		wind.log.Warningf("(wind) failed to stop: %v", err)
+	}
 
-		if wind.ctx.tid != 0 {
-			if r0, err := postMessage0012(wind.ctx.tid); r0 != 0 {
-				wind.wg.Wait()
-			} else {
-				klog.Warningf("(wind) post close message with error: %v(%v)", err, r0)
-			}
-			wind.ctx.tid = 0
-		}
+	wind.ctx.cancel()
+	if err := wind.backend.close(); err != nil {
+		wind.err = errs.And(wind.err, err)
 	}
+	wind.wg.Wait()
 
 	return wind.err
 }
 
 func (wind *windObj) ioloop() {
-	klog.Info("(wind) ioloop started")
+	wind.log.Infof("(wind) ioloop started")
 	defer wind.wg.Done()
 
 	var (
@@ -317,7 +536,7 @@ func (wind *windObj) ioloop() {
 	)
 
 	defer func() {
-		klog.Infof("(wind) ioloop exited, deliveried #%d messages", evtCnt)
+		wind.log.Infof("(wind) ioloop exited, deliveried #%d messages", evtCnt)
 	}()
 
 IOLOOP:
@@ -332,179 +551,239 @@ IOLOOP:
 			break IOLOOP
 		}
 
+		reqid := uint64(evt.RequestID)
+		wind.io.RLock()
+		f, isFuture := wind.io.futures[reqid]
+		wind.io.RUnlock()
+		if isFuture {
+			f.complete(evt)
+			continue
+		}
+
 		if evt.State != 1 {
-			klog.Warningf("(wind) io, unrecognized state code: %d", evt.State)
+			err := wind.watch(parseErr(evt.ErrCode))
+			wind.log.Warningf("(wind) io, request %d with state code: %d, %v", reqid, evt.State, err)
+			wind.io.RLock()
+			if subs, ok := wind.io.ds[reqid]; ok && !subs.closed {
+				subs.report(SubscriptionEvent{Kind: EventState, ReqID: reqid, State: evt.State, Err: err})
+			}
+			wind.io.RUnlock()
 			continue
 		}
 
-		reqid := uint64(evt.RequestID)
 		data, err := wind.readdata(reqid)
 		if err != nil {
-			klog.Errorf("(wind) io, failed to get updates for %d", reqid)
+			wind.log.Errorf("(wind) io, failed to get updates for %d", reqid)
+			wind.watch(err)
 			continue
 		}
 
 		wind.io.RLock()
-		if subs, ok := wind.io.ds[reqid]; ok {
-			select {
-			case subs.c <- data:
-			case <-wind.ctx.Done():
-				klog.Warningf("(wind) io, canceled when sending data to %d, may loose data", reqid)
-				wind.io.RUnlock()
-				break IOLOOP
-			}
-		}
+		subs, ok := wind.io.ds[reqid]
+		// a former request of a subscription may be still mapped after it's closed
+		ok = ok && !subs.closed
 		wind.io.RUnlock()
+
+		// push without holding wind.io, the consumer may be slow
+		if ok && !subs.push(data, wind.ctx.Done()) {
+			wind.log.Warningf("(wind) io, canceled when sending data to %d, may loose data", reqid)
+			break IOLOOP
+		}
 	}
 
 	wind.io.Lock()
 	defer wind.io.Unlock()
-	for key := range wind.io.ds {
-		subs := wind.io.ds[key]
-		subs.err = ErrClosing
-		close(subs.c)
-
-		// clean
-		wind.io.ds[key] = nil
+	for key, subs := range wind.io.ds {
+		// subscriptions being closed are cleaned by themselves
+		if !subs.closed {
+			subs.closed = true
+			subs.err = ErrClosing
+			subs.shutdown()
+		}
 		delete(wind.io.ds, key)
 	}
+	for key, f := range wind.io.futures {
+		f.finish(nil, ErrClosing)
+		delete(wind.io.futures, key)
+	}
 }
 
-func (wind *windObj) enableAsyn() (err error) {
-	_, err = callMethod(wind.wind, "enableAsyn", 1)
-	return
+// startIO starts ioloop lazily
+func (wind *windObj) startIO() {
+	wind.io.Do(func() {
+		wind.io.ds = make(map[uint64]*Subscription)
+		wind.io.futures = make(map[uint64]*Future)
+		wind.wg.Add(1)
+		go wind.ioloop()
+	})
+}
+
+// bind returns a context which is done once either ctx is done or wind is closed
+func (wind *windObj) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-wind.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// paceSubscribe waits for limits.subscribe until ctx is done or wind is closed,
+// it must be called before locking wind.io, so that ioloop is not blocked while waiting
+func (wind *windObj) paceSubscribe(ctx context.Context) error {
+	ctx, cancel := wind.bind(ctx)
+	defer cancel()
+	return wind.limits.subscribe.wait(ctx)
+}
+
+func (wind *windObj) enableAsyn() error {
+	return wind.backend.enableAsyn()
 }
 
 func (wind *windObj) stop() error {
-	res, err := callMethod(wind.wind, "stop")
+	ec, err := wind.backend.stop()
 	if err != nil {
 		return err
 	}
-	if err = parseErr(int32(res.Val)); err != nil {
-		return err
-	}
-	return nil
+	return parseErr(ec)
 }
 
 func (wind *windObj) start(option1, option2 string, timeout int32) error {
-	res, err := callMethod(wind.wind, "start_cpp", option1, option2, timeout)
+	ec, err := wind.backend.start(option1, option2, timeout)
 	if err != nil {
 		return err
 	}
-	if err = parseErr(int32(res.Val)); err != nil {
-		return err
-	}
-	return nil
+	return parseErr(ec)
 }
 
+// cancel cancels a request, it never fails fast, a request given up locally keeps streaming otherwise
 func (wind *windObj) cancel(reqid uint64) error {
-	_, err := callMethod(wind.wind, "cancelRequest", reqid)
-	if err != nil {
+	if err := wind.limits.subscribe.waitAlways(wind.ctx); err != nil {
 		return err
 	}
-	return nil
+	return wind.backend.cancel(reqid)
 }
 
+// wsq issues a realtime request, callers should pace it using limits.subscribe
 func (wind *windObj) wsq(codes, fields, options string) (reqid uint64, err error) {
-	var (
-		res     *ole.VARIANT
-		errCode int32
-	)
 	options += ";REALTIME=Y"
-	res, err = callMethod(wind.wind, "wsq", codes, fields, options, &errCode)
+	reqid, errCode, err := wind.backend.request("wsq", codes, fields, options)
 	if err != nil {
-		return
+		return 0, err
 	}
 	if err = parseErr(errCode); err != nil {
 		return 0, err
 	}
-	reqid = uint64(res.Val)
 	return reqid, nil
 }
 
-type rawData struct {
-	data      ole.VARIANT // 数据
-	codes     ole.VARIANT // Code列表
-	fields    ole.VARIANT // 指标列表
-	times     ole.VARIANT // 时间列表
-	stateCode int32       // 状态码
-}
-
 func (wind *windObj) readdata(reqid uint64) (data []*WindData, err error) {
-	var rs int32
-	return wind.getWindData(func(codes, fields, times *ole.VARIANT, ec *int32) (*ole.VARIANT, error) {
-		return callMethod(wind.wind, "readdata", reqid, codes, fields, times, &rs, ec)
-	})
-}
-
-func (wind *windObj) getWindData(fn func(codes, fields, times *ole.VARIANT, ec *int32) (*ole.VARIANT, error)) (data []*WindData, err error) {
-	var (
-		raw rawData
-		rs  int32
-		ec  int32
-	)
-	res, err := fn(&raw.codes, &raw.fields, &raw.times, &ec)
-	raw.data = *res
-	raw.stateCode = rs
-
+	raw, err := wind.readRaw(reqid)
 	if err != nil {
 		return nil, err
 	}
-	if err = parseErr(ec); err != nil {
+	if err = parseErr(raw.errCode); err != nil {
 		return nil, err
 	}
-	data, err = parseRawData(&raw)
-	err = errs.And(err, raw.codes.Clear(), raw.fields.Clear(), raw.times.Clear(), raw.data.Clear())
-	return
+	return parseRawData(raw)
 }
 
-func checkSafeArray(name string, v *ole.VARIANT) (*ole.SafeArrayConversion, error) {
-	arr := v.ToArray()
-	if arr == nil {
-		return nil, fmt.Errorf("wind: invlalid %s", name)
+// readRaw reads data notified by an event, it waits for a token even if the limit fails fast,
+// since the data would be lost otherwise
+func (wind *windObj) readRaw(reqid uint64) (*rawData, error) {
+	if err := wind.limits.subscribe.waitAlways(wind.ctx); err != nil {
+		return nil, err
 	}
-	return arr, nil
+	return wind.backend.readdata(reqid)
 }
 
-func msTsToTime(msts float64) time.Time {
-	val := msts - 693960
-	day := time.Duration(int64(val))
-	ns := time.Millisecond * time.Duration((val*float64(time.Hour*24)-float64(day*time.Hour*24))/float64(time.Millisecond)+0.5)
-	return time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local).Add(day*time.Hour*24 + ns)
+func (wind *windObj) getWindData(opts []CallOption, method string, args ...interface{}) (data []*WindData, err error) {
+	err = wind.getRawData(opts, func(raw *rawData) (err error) {
+		data, err = parseRawData(raw)
+		return
+	}, method, args...)
+	return
 }
 
-func parseRawData(raw *rawData) ([]*WindData, error) {
-	val, err := checkSafeArray("codes", &raw.codes)
-	if val == nil {
-		return nil, err
-	}
-	codes := val.ToStringArray()
-	val, err = checkSafeArray("fields", &raw.fields)
-	if val == nil {
-		return nil, err
-	}
-	fields := val.ToStringArray()
-	val, err = checkSafeArray("times", &raw.times)
-	if val == nil {
-		return nil, err
+func (wind *windObj) getTable(opts []CallOption, method string, args ...interface{}) (table *Table, err error) {
+	err = wind.getRawData(opts, func(raw *rawData) (err error) {
+		table, err = parseRawTable(raw)
+		return
+	}, method, args...)
+	return
+}
+
+// getRawData calls a synchronous method, and passes its result to parse if no error occurs,
+// each attempt is paced by limits.query, and retried according to the retry policy in opts.
+// If a context is given by WithContext, the method is issued as a request without the _syn suffix,
+// so that it can be canceled once the context is done.
+func (wind *windObj) getRawData(opts []CallOption, parse func(raw *rawData) error, method string, args ...interface{}) error {
+	o := newCallOptions(opts)
+	ctx := context.Context(wind.ctx)
+	if o.ctx != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = wind.bind(o.ctx)
+		defer cancel()
+	}
+	// reqid is the last request issued with the context
+	var reqid uint64
+	err := o.retry.do(ctx, func() error {
+		if err := wind.limits.query.wait(ctx); err != nil {
+			return err
+		}
+		var (
+			raw *rawData
+			err error
+		)
+		if o.ctx == nil {
+			raw, err = wind.backend.query(method, args...)
+		} else {
+			// the asynchronous version can be canceled
+			var f *Future
+			if f, err = wind.request(strings.TrimSuffix(method, "_syn"), args...); err == nil {
+				reqid = f.reqid
+				raw, err = f.wait(ctx)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if err = parseErr(raw.errCode); err != nil {
+			return wind.watch(err)
+		}
+		return parse(raw)
+	})
+	// done while backing off
+	if err != nil && err == ctx.Err() {
+		if wind.ctx.Err() != nil {
+			return ErrClosing
+		}
+		if reqid != 0 {
+			return fmt.Errorf("wind: request %d: %w", reqid, err)
+		}
 	}
-	times := val.ToValueArray()
-	val, err = checkSafeArray("data", &raw.data)
-	if val == nil {
-		return nil, err
+	return err
+}
+
+func parseRawData(raw *rawData) ([]*WindData, error) {
+	w := len(raw.fields)
+	n := len(raw.codes)
+	data := raw.data
+	if len(data) < len(raw.times)*n*w {
+		return nil, fmt.Errorf("wind: invalid data, got %d values for %d codes, %d fields and %d times", len(data), n, w, len(raw.times))
 	}
-	data := val.ToValueArray()
 
-	w := len(fields)
-	out := make([]*WindData, len(times)*len(codes))
+	out := make([]*WindData, len(raw.times)*n)
 	ctime := time.Now()
-	for i, tval := range times {
-		tm := msTsToTime(tval.(float64))
-		for j, code := range codes {
-			out[i+j] = &WindData{
+	for i, tm := range raw.times {
+		for j, code := range raw.codes {
+			out[i*n+j] = &WindData{
 				UpdateTime: tm,
 				WindCode:   code,
-				Fields:     fields[:],
+				Fields:     raw.fields[:],
 				Values:     data[0:w],
 				CreatedAt:  ctime,
 			}
@@ -514,3 +793,18 @@ func parseRawData(raw *rawData) ([]*WindData, error) {
 
 	return out, nil
 }
+
+func parseRawTable(raw *rawData) (*Table, error) {
+	data := raw.data
+	table := &Table{Columns: raw.fields}
+	w := len(raw.fields)
+	if w == 0 {
+		return table, nil
+	}
+	table.Rows = make([][]interface{}, 0, len(data)/w)
+	for len(data) >= w {
+		table.Rows = append(table.Rows, data[0:w])
+		data = data[w:]
+	}
+	return table, nil
+}
@@ -1,3 +1,5 @@
+// +build windows
+
 package windapi
 
 import (
@@ -11,6 +13,19 @@ func TestNewRelease(t *testing.T) {
 	panicOnErr(wind.close())
 }
 
+func TestNewClient(t *testing.T) {
+	c1, err := New(WithStartTimeout(10 * time.Second))
+	panicOnErr(err)
+	c2, err := New()
+	panicOnErr(err)
+
+	panicOnErr(c1.Close())
+	if !c2.IsConnected() {
+		t.Error("expected the other client to stay connected")
+	}
+	panicOnErr(c2.Close())
+}
+
 func TestWindwsq(t *testing.T) {
 	wind, err := newAPI()
 	panicOnErr(err)
@@ -60,6 +75,18 @@ func TestWSQ(t *testing.T) {
 	}
 }
 
+func TestWSQSnapshot(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	codes := "IF1703.CFE,USDCNY.IB,CU1701.SHF"
+	fields := "rt_date,rt_time,rt_last,rt_latest"
+	data, err := wind.WSQSnapshot(codes, fields)
+	panicOnErr(err)
+	t.Log(data)
+}
+
 func TestWSS(t *testing.T) {
 	wind, err := newAPI()
 	panicOnErr(err)
@@ -72,8 +99,88 @@ func TestWSS(t *testing.T) {
 	t.Log(data)
 }
 
-func panicOnErr(err error) {
-	if err != nil {
-		panic(err)
+func TestWSD(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	codes := "600588.SH,000001.SZ"
+	end := time.Now()
+	series, err := wind.WSD(codes, "close", end.AddDate(0, 0, -10), end, "")
+	panicOnErr(err)
+	t.Log(series)
+}
+
+func TestWSI(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	end := time.Now()
+	data, err := wind.WSI("600588.SH", "open,high,low,close,volume", end.AddDate(0, 0, -3), end, "BarSize=5")
+	panicOnErr(err)
+	t.Log(data)
+}
+
+func TestWST(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	end := time.Now()
+	data, err := wind.WST("600588.SH", "last,volume,ask1,bid1", end.Add(-time.Hour), end, "")
+	panicOnErr(err)
+	t.Log(data)
+}
+
+func TestEDB(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	end := time.Now()
+	series, err := wind.EDB("M0001385", end.AddDate(-1, 0, 0), end, "")
+	panicOnErr(err)
+	t.Log(series)
+}
+
+func TestWSES(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	end := time.Now()
+	series, err := wind.WSES("a001010100000000", "sec_close_avg", end.AddDate(0, 0, -10), end, "")
+	panicOnErr(err)
+	snapshot, err := wind.WSEE("a001010100000000", "sec_pe_avg_chn", "")
+	panicOnErr(err)
+	t.Log(series, snapshot)
+}
+
+func TestWSET(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	table, err := wind.WSET("sectorconstituent", "date="+time.Now().Format(dateLayout)+";sectorid=a001010100000000")
+	panicOnErr(err)
+	t.Log(table.Column("wind_code"))
+}
+
+func TestTDays(t *testing.T) {
+	wind, err := newAPI()
+	panicOnErr(err)
+	defer func() { panicOnErr(wind.close()) }()
+
+	end := time.Now()
+	days, err := wind.TDays(end.AddDate(0, -1, 0), end, "TradingCalendar=SZSE")
+	panicOnErr(err)
+	count, err := wind.TDaysCount(end.AddDate(0, -1, 0), end, "TradingCalendar=SZSE")
+	panicOnErr(err)
+	if count != len(days) {
+		t.Errorf("expected %d trading days, got %d", len(days), count)
 	}
+	day, err := wind.TDaysOffset(end, -1, "Period=W")
+	panicOnErr(err)
+	t.Log(days, day)
 }